@@ -1,7 +1,36 @@
 package main
 
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
 type actionType uint16
 
+func (a actionType) String() string {
+	s, ok := actionType_name[int(a)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(a))
+}
+
+func (a actionType) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *actionType) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := actionType_value[s]; ok {
+		*a = actionType(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
 const (
 	ACTION_INVALID actionType = iota
 
@@ -12,10 +41,101 @@ const (
 	ACTION_GOAL_OWN
 
 	ACTION_ASSIST
+
+	ACTION_SUBSTITUTION
+
+	ACTION_PENALTY_SCORED
+	ACTION_PENALTY_SAVED
+	ACTION_PENALTY_MISSED
 )
 
+var actionType_name = map[int]string{
+	0: "ACTION_INVALID",
+	1: "ACTION_CARD_YELLOW",
+	2: "ACTION_CARD_RED",
+	3: "ACTION_GOAL",
+	4: "ACTION_GOAL_OWN",
+	5: "ACTION_ASSIST",
+	6: "ACTION_SUBSTITUTION",
+	7: "ACTION_PENALTY_SCORED",
+	8: "ACTION_PENALTY_SAVED",
+	9: "ACTION_PENALTY_MISSED",
+}
+
+var actionType_value = map[string]int{
+	"ACTION_INVALID":        0,
+	"ACTION_CARD_YELLOW":    1,
+	"ACTION_CARD_RED":       2,
+	"ACTION_GOAL":           3,
+	"ACTION_GOAL_OWN":       4,
+	"ACTION_ASSIST":         5,
+	"ACTION_SUBSTITUTION":   6,
+	"ACTION_PENALTY_SCORED": 7,
+	"ACTION_PENALTY_SAVED":  8,
+	"ACTION_PENALTY_MISSED": 9,
+}
+
+// isPenaltyKick reports whether the action is a kick of a penalty shootout.
+func (a actionType) isPenaltyKick() bool {
+	return a == ACTION_PENALTY_SCORED || a == ACTION_PENALTY_SAVED || a == ACTION_PENALTY_MISSED
+}
+
+// action is an event recorded during a match. LineupID is the lineup of the
+// match the player was playing for. On substitutions PlayerID is the player
+// coming off and SubstituteID the one coming on. Assists point to the goal
+// they belong to through GoalID. On penalty shootout kicks PlayerID is the
+// kicker and GoalkeeperID the goalkeeper facing the kick. Time is the moment
+// of the match it happened at and RecordedAt the wall-clock time. Annulled
+// actions are kept for the record but do not count for anything. Actions
+// derived by the rules of the game are not stored, DerivedFrom is the action
+// they follow from.
 type action struct {
-	PlayerID  int64      `json:"player_id,omitempty" db:"player_id,omitempty"`
-	Type      actionType `json:"action,omitempty" db:"action,omitempty"`
-	Timestamp uint64
+	ActionID     int64      `json:"action_id,omitempty" db:"action_id,omitempty"`
+	MatchID      int64      `json:"match_id,omitempty" db:"match_id,omitempty"`
+	LineupID     int64      `json:"lineup_id,omitempty" db:"lineup_id,omitempty"`
+	PlayerID     int64      `json:"player_id,omitempty" db:"player_id,omitempty"`
+	SubstituteID *int64     `json:"substitute_id,omitempty" db:"substitute_id,omitempty"`
+	GoalID       *int64     `json:"goal_id,omitempty" db:"goal_id,omitempty"`
+	GoalkeeperID *int64     `json:"goalkeeper_id,omitempty" db:"goalkeeper_id,omitempty"`
+	Type         actionType `json:"action,omitempty" db:"action,omitempty"`
+	Time         matchTime  `json:"time" db:"clock"`
+	RecordedAt   *time.Time `json:"recorded_at,omitempty" db:"recorded_at,omitempty"`
+	Annulled     bool       `json:"annulled,omitempty" db:"annulled"`
+	DerivedFrom  int64      `json:"derived_from,omitempty" db:"-"`
+}
+
+// actionSnapshot is an action as it was before being corrected, stored as
+// JSON.
+type actionSnapshot action
+
+func (a actionSnapshot) Value() (driver.Value, error) {
+	return json.Marshal(action(a))
+}
+
+func (a *actionSnapshot) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into an action", src)
+	}
+	return json.Unmarshal(b, (*action)(a))
+}
+
+// actionChange is a request to correct or annul an action. Who made the
+// change and why are mandatory.
+type actionChange struct {
+	action
+	ChangedBy string `json:"changed_by"`
+	Reason    string `json:"reason"`
+}
+
+// actionCorrection is the audit record of a change made to an action.
+// Previous is the action as it was before the change.
+type actionCorrection struct {
+	CorrectionID int64          `json:"correction_id,omitempty" db:"correction_id,omitempty"`
+	ActionID     int64          `json:"action_id,omitempty" db:"action_id,omitempty"`
+	Annulment    bool           `json:"annulment,omitempty" db:"annulment"`
+	ChangedBy    string         `json:"changed_by" db:"changed_by"`
+	Reason       string         `json:"reason" db:"reason"`
+	ChangedAt    *time.Time     `json:"changed_at,omitempty" db:"changed_at,omitempty"`
+	Previous     actionSnapshot `json:"previous" db:"previous"`
 }
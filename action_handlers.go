@@ -0,0 +1,504 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func actionID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("action_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("action_id", str).Debug("Failed to parse `action_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `action_id`")
+		}
+
+		c.Set("action_id", id)
+
+		return next(c)
+	}
+}
+
+func getActionID(c echo.Context) (id int64) {
+	id, _ = c.Get("action_id").(int64)
+	return
+}
+
+const (
+	actionsTable           = "actions"
+	actionCorrectionsTable = "action_corrections"
+)
+
+var (
+	errActionNotFound     = errors.New("action not found")
+	errPlayerNotInLineups = errors.New("player is not in any of the match lineups")
+	errActionAnnulled     = errors.New("action has been annulled")
+	errMissingChange      = errors.New("`changed_by` and `reason` must be set")
+)
+
+func findAction(sess sqlbuilder.SQLBuilder, matchID, actionID int64) (*action, error) {
+	found := new(action)
+
+	err := sess.SelectFrom(actionsTable).Where("match_id", matchID).
+		And("action_id", actionID).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errActionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func (s *server) createAction(c echo.Context) error {
+	req := new(action)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure ActionID, MatchID, LineupID, RecordedAt and Annulled are not set.
+	if req.ActionID != 0 || req.MatchID != 0 || req.LineupID != 0 || req.RecordedAt != nil || req.Annulled {
+		log.WithError(fmt.Errorf("action_id, match_id, lineup_id, recorded_at or annulled was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if _, ok := actionType_name[int(req.Type)]; !ok || req.Type == ACTION_INVALID {
+		log.WithError(fmt.Errorf("Invalid `action` value")).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `action` value")
+	}
+
+	if req.PlayerID == int64(0) {
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.Time.IsZero() {
+		log.WithError(fmt.Errorf("`time` was not set")).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `time` value")
+	}
+
+	var (
+		m       *match
+		derived []action
+	)
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		var err error
+
+		m, err = lockMatch(tx, getMatchID(c))
+		if err != nil {
+			return err
+		}
+
+		st, actions, err := s.matchState(tx, m)
+		if err != nil {
+			return err
+		}
+
+		req.MatchID = m.MatchID
+
+		req.LineupID, err = st.playerLineup(req.PlayerID)
+		if err != nil {
+			return err
+		}
+
+		n := st.replay(actions, req.Time)
+
+		if err := st.check(req); err != nil {
+			return err
+		}
+
+		now := s.now()
+		req.RecordedAt = &now
+
+		ret, err := tx.Collection(actionsTable).Insert(req)
+		if err != nil {
+			return err
+		}
+
+		req.ActionID, err = toInt64(ret)
+		if err != nil {
+			return err
+		}
+
+		derived = st.apply(req)
+
+		// The actions recorded later in the match must still hold.
+		if err := st.validate(actions[n:]); err != nil {
+			return err
+		}
+
+		return s.refreshMatchStats(tx, m)
+	})
+	if err != nil {
+		return actionError(c, err)
+	}
+
+	s.publish(m.MatchID, eventAction, req)
+	for i := range derived {
+		s.publish(m.MatchID, eventAction, &derived[i])
+	}
+	if req.Type == ACTION_GOAL || req.Type == ACTION_GOAL_OWN {
+		s.publishScore(m)
+		s.purgeStandings(m.SeasonID)
+	}
+	s.purgeLeaderboards(m.SeasonID)
+
+	return c.JSON(http.StatusOK, &action{
+		ActionID: req.ActionID,
+	})
+}
+
+// actionError maps the errors of recording an action to a response.
+func actionError(c echo.Context, err error) error {
+	switch err {
+	case errMatchNotFound:
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errActionNotFound:
+		log.WithField("action_id", getActionID(c)).Debug("action not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errActionAnnulled:
+		log.WithField("action_id", getActionID(c)).Debug("action already annulled")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errPlayerNotInLineups, errPlayerNotOnPitch, errNotASubstitute, errSubstituteUsed,
+		errMaxSubstitutions, errInvalidSubstitution, errInvalidGoalLink, errAssistWithoutGoal,
+		errSelfAssist, errGoalAlreadyAssisted, errPlayerSentOff, errInvalidGoalkeeper,
+		errKickOutsideShootout, errNotAShootoutAction, errScoreNotLevel, errShootoutDecided,
+		errKickerNotOnPitch, errGoalkeeperNotFacing, errKickOutOfTurn:
+		log.WithError(err).Debug("Invalid action")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errTxConflict:
+		log.WithError(err).Debug("Conflicting match update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	log.WithError(err).Error("Failed to insert action in the store")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+func (s *server) listActions(c echo.Context) error {
+	_, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	filter := db.Cond{"match_id": getMatchID(c)}
+	if c.QueryParam("with-annulled") != "true" {
+		filter["annulled"] = false
+	}
+
+	var actions []action
+
+	err = s.db.Collection(actionsTable).Find(filter).
+		OrderBy("clock", "action_id").All(&actions)
+	if err != nil {
+		log.WithError(err).Error("Failed to list actions from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &actions)
+}
+
+func (s *server) getAction(c echo.Context) error {
+	found := new(action)
+
+	err := s.db.Collection(actionsTable).Find("match_id", getMatchID(c)).
+		And("action_id", getActionID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("action_id", getActionID(c)).Debug("action not found")
+		return echo.NewHTTPError(http.StatusNotFound, errActionNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve action from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+// bindActionChange binds a request to change an action, ensuring it says
+// who is making the change and why.
+func bindActionChange(c echo.Context) (*actionChange, error) {
+	req := new(actionChange)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if req.ChangedBy == "" || req.Reason == "" {
+		log.WithError(errMissingChange).Error("Invalid request")
+		return nil, echo.NewHTTPError(http.StatusUnprocessableEntity, errMissingChange.Error())
+	}
+
+	return req, nil
+}
+
+// correctAction amends an action, keeping the previous version of it in the
+// audit trail. The actions of the match must still make sense once the
+// correction is applied.
+func (s *server) correctAction(c echo.Context) error {
+	req, err := bindActionChange(c)
+	if err != nil {
+		return err
+	}
+
+	// Ensure ActionID, MatchID, LineupID, RecordedAt and Annulled are not set.
+	if req.ActionID != 0 || req.MatchID != 0 || req.LineupID != 0 || req.RecordedAt != nil || req.Annulled {
+		log.WithError(fmt.Errorf("action_id, match_id, lineup_id, recorded_at or annulled was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if _, ok := actionType_name[int(req.Type)]; !ok {
+		log.WithError(fmt.Errorf("Invalid `action` value")).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `action` value")
+	}
+
+	var (
+		corrected action
+		m         *match
+	)
+
+	err = s.tx(func(tx sqlbuilder.Tx) error {
+		var err error
+
+		m, err = lockMatch(tx, getMatchID(c))
+		if err != nil {
+			return err
+		}
+
+		found, err := findAction(tx, m.MatchID, getActionID(c))
+		if err != nil {
+			return err
+		}
+		if found.Annulled {
+			return errActionAnnulled
+		}
+
+		corrected = *found
+		if req.PlayerID != 0 {
+			corrected.PlayerID = req.PlayerID
+		}
+		if req.Type != ACTION_INVALID {
+			corrected.Type = req.Type
+		}
+		if !req.Time.IsZero() {
+			corrected.Time = req.Time
+		}
+		if req.SubstituteID != nil {
+			corrected.SubstituteID = req.SubstituteID
+		}
+		if req.GoalID != nil {
+			corrected.GoalID = req.GoalID
+		}
+		if req.GoalkeeperID != nil {
+			corrected.GoalkeeperID = req.GoalkeeperID
+		}
+
+		// Drop the links that no longer apply after changing the type.
+		if req.Type != ACTION_INVALID && req.Type != found.Type {
+			if corrected.Type != ACTION_SUBSTITUTION && req.SubstituteID == nil {
+				corrected.SubstituteID = nil
+			}
+			if corrected.Type != ACTION_ASSIST && req.GoalID == nil {
+				corrected.GoalID = nil
+			}
+			if !corrected.Type.isPenaltyKick() && req.GoalkeeperID == nil {
+				corrected.GoalkeeperID = nil
+			}
+		}
+
+		st, actions, err := s.matchState(tx, m)
+		if err != nil {
+			return err
+		}
+
+		corrected.LineupID, err = st.playerLineup(corrected.PlayerID)
+		if err != nil {
+			return err
+		}
+
+		for i := range actions {
+			if actions[i].ActionID == corrected.ActionID {
+				actions[i] = corrected
+			}
+		}
+		sortActions(actions)
+
+		if err := st.validate(actions); err != nil {
+			return err
+		}
+
+		// Pick up the goal an assist may have been linked to.
+		for i := range actions {
+			if actions[i].ActionID == corrected.ActionID {
+				corrected = actions[i]
+			}
+		}
+
+		err = tx.Collection(actionsTable).Find("action_id", corrected.ActionID).Update(map[string]interface{}{
+			"lineup_id":     corrected.LineupID,
+			"player_id":     corrected.PlayerID,
+			"substitute_id": corrected.SubstituteID,
+			"goal_id":       corrected.GoalID,
+			"goalkeeper_id": corrected.GoalkeeperID,
+			"action":        corrected.Type,
+			"clock":         corrected.Time,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Collection(actionCorrectionsTable).Insert(&actionCorrection{
+			ActionID:  found.ActionID,
+			ChangedBy: req.ChangedBy,
+			Reason:    req.Reason,
+			Previous:  actionSnapshot(*found),
+		})
+		if err != nil {
+			return err
+		}
+
+		return s.refreshMatchStats(tx, m)
+	})
+	if err != nil {
+		return actionError(c, err)
+	}
+
+	s.publish(m.MatchID, eventAction, &corrected)
+	s.publishScore(m)
+	s.purgeStandings(m.SeasonID)
+	s.purgeLeaderboards(m.SeasonID)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// annulAction annuls an action instead of deleting it, so it stays in the
+// record. Assists are annulled together with the goal they belong to.
+func (s *server) annulAction(c echo.Context) error {
+	req, err := bindActionChange(c)
+	if err != nil {
+		return err
+	}
+
+	var (
+		annulled []action
+		m        *match
+	)
+
+	err = s.tx(func(tx sqlbuilder.Tx) error {
+		var err error
+
+		m, err = lockMatch(tx, getMatchID(c))
+		if err != nil {
+			return err
+		}
+
+		found, err := findAction(tx, m.MatchID, getActionID(c))
+		if err != nil {
+			return err
+		}
+		if found.Annulled {
+			return errActionAnnulled
+		}
+
+		st, actions, err := s.matchState(tx, m)
+		if err != nil {
+			return err
+		}
+
+		annulled = nil
+
+		var remaining []action
+		for _, a := range actions {
+			if a.ActionID == found.ActionID || (a.GoalID != nil && *a.GoalID == found.ActionID) {
+				annulled = append(annulled, a)
+				continue
+			}
+			remaining = append(remaining, a)
+		}
+
+		if err := st.validate(remaining); err != nil {
+			return err
+		}
+
+		for i := range annulled {
+			a := &annulled[i]
+
+			err = tx.Collection(actionsTable).Find("action_id", a.ActionID).Update(map[string]interface{}{
+				"annulled": true,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Collection(actionCorrectionsTable).Insert(&actionCorrection{
+				ActionID:  a.ActionID,
+				Annulment: true,
+				ChangedBy: req.ChangedBy,
+				Reason:    req.Reason,
+				Previous:  actionSnapshot(*a),
+			})
+			if err != nil {
+				return err
+			}
+
+			a.Annulled = true
+		}
+
+		return s.refreshMatchStats(tx, m)
+	})
+	if err != nil {
+		return actionError(c, err)
+	}
+
+	for i := range annulled {
+		s.publish(m.MatchID, eventAction, &annulled[i])
+	}
+	s.publishScore(m)
+	s.purgeStandings(m.SeasonID)
+	s.purgeLeaderboards(m.SeasonID)
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) listActionCorrections(c echo.Context) error {
+	_, err := findAction(s.db, getMatchID(c), getActionID(c))
+	if err == errActionNotFound {
+		log.WithField("action_id", getActionID(c)).Debug("action not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve action from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var corrections []actionCorrection
+
+	err = s.db.Collection(actionCorrectionsTable).Find("action_id", getActionID(c)).
+		OrderBy("changed_at", "correction_id").All(&corrections)
+	if err != nil {
+		log.WithError(err).Error("Failed to list action corrections from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &corrections)
+}
@@ -0,0 +1,1673 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchActions(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 4, Position: POSITION_DEFENDER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 1, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1)},
+		{LineupID: int64(2), PlayerID: int64(2)},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	kickoff := time.Date(2019, time.June, 1, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return kickoff }
+
+	_, err := s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Unknown action type",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"player_id": 1,
+				"action":    "ACTION_FOO",
+			},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:   "Player not in the match lineups",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_GOAL,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is not in any of the match lineups"}`,
+		},
+		{
+			Name:   "Unknown match",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+			},
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"match not found"}`,
+		},
+		{
+			Name:   "Action already annulled",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(12, 0),
+				Annulled: true,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Action with its recording time",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:   int64(1),
+				Type:       ACTION_GOAL,
+				Time:       at(12, 0),
+				RecordedAt: &kickoff,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Home player scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(12, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Away player gets booked",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(45, 2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:               "List match actions",
+			Method:             "GET",
+			Target:             "/matches/1/actions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"match_id":1,"lineup_id":1,"player_id":1,"action":"ACTION_GOAL","time":"12'","recorded_at":"2019-06-01T18:00:00Z"},{"action_id":2,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_CARD_YELLOW","time":"45+2'","recorded_at":"2019-06-01T18:00:00Z"}]`,
+		},
+		{
+			Name:               "Score counts the home goal",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1,"home_lineup_id":1,"away_lineup_id":2,"status":"MATCH_STATUS_LIVE","score":{"home":1,"away":0}}`,
+		},
+		{
+			Name:   "Away player scores an own goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_GOAL_OWN,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:               "Own goal counts for the home side",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1,"home_lineup_id":1,"away_lineup_id":2,"status":"MATCH_STATUS_LIVE","score":{"home":2,"away":0}}`,
+		},
+		{
+			Name:   "Annul own goal without a reason",
+			Method: "DELETE",
+			Target: "/matches/1/actions/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"` + "`changed_by` and `reason` must be set" + `"}`,
+		},
+		{
+			Name:   "Annul own goal",
+			Method: "DELETE",
+			Target: "/matches/1/actions/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Foul in the build-up",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Score no longer counts the own goal",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1,"home_lineup_id":1,"away_lineup_id":2,"status":"MATCH_STATUS_LIVE","score":{"home":1,"away":0}}`,
+		},
+		{
+			Name:   "Annul own goal twice",
+			Method: "DELETE",
+			Target: "/matches/1/actions/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Foul in the build-up",
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"action has been annulled"}`,
+		},
+		{
+			Name:   "Correct annulled own goal",
+			Method: "PUT",
+			Target: "/matches/1/actions/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"time":       "31'",
+				"changed_by": "Referee",
+				"reason":     "Wrong minute",
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"action has been annulled"}`,
+		},
+		{
+			Name:   "Correct goal to a player not in the lineups",
+			Method: "PUT",
+			Target: "/matches/1/actions/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"player_id":  3,
+				"changed_by": "Referee",
+				"reason":     "Wrong scorer",
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is not in any of the match lineups"}`,
+		},
+		{
+			Name:   "Correct unknown action",
+			Method: "PUT",
+			Target: "/matches/1/actions/9",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "Referee",
+				"reason":     "Wrong minute",
+			},
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"action not found"}`,
+		},
+		{
+			Name:   "Correct booking into a red card",
+			Method: "PUT",
+			Target: "/matches/1/actions/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"action":     "ACTION_CARD_RED",
+				"changed_by": "VAR",
+				"reason":     "Violent conduct",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get corrected booking",
+			Method:             "GET",
+			Target:             "/matches/1/actions/2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_CARD_RED","time":"45+2'","recorded_at":"2019-06-01T18:00:00Z"}`,
+		},
+		{
+			Name:               "Annulled own goal is kept",
+			Method:             "GET",
+			Target:             "/matches/1/actions/3",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_GOAL_OWN","time":"30'","recorded_at":"2019-06-01T18:00:00Z","annulled":true}`,
+		},
+		{
+			Name:               "List match actions leaves annulled ones out",
+			Method:             "GET",
+			Target:             "/matches/1/actions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"match_id":1,"lineup_id":1,"player_id":1,"action":"ACTION_GOAL","time":"12'","recorded_at":"2019-06-01T18:00:00Z"},{"action_id":2,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_CARD_RED","time":"45+2'","recorded_at":"2019-06-01T18:00:00Z"}]`,
+		},
+		{
+			Name:               "List match actions with annulled ones",
+			Method:             "GET",
+			Target:             "/matches/1/actions?with-annulled=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"match_id":1,"lineup_id":1,"player_id":1,"action":"ACTION_GOAL","time":"12'","recorded_at":"2019-06-01T18:00:00Z"},{"action_id":3,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_GOAL_OWN","time":"30'","recorded_at":"2019-06-01T18:00:00Z","annulled":true},{"action_id":2,"match_id":1,"lineup_id":2,"player_id":2,"action":"ACTION_CARD_RED","time":"45+2'","recorded_at":"2019-06-01T18:00:00Z"}]`,
+		},
+		{
+			Name:               "Corrections of unknown action",
+			Method:             "GET",
+			Target:             "/matches/1/actions/9/corrections",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"action not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/matches/1/actions/2/corrections", nil)
+	rec := httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+
+	var corrections []actionCorrection
+	r.Nil(json.Unmarshal(rec.Body.Bytes(), &corrections))
+	r.Len(corrections, 1)
+	r.NotNil(corrections[0].ChangedAt)
+	r.Equal("VAR", corrections[0].ChangedBy)
+	r.Equal("Violent conduct", corrections[0].Reason)
+	r.False(corrections[0].Annulment)
+	r.Equal(ACTION_CARD_YELLOW, corrections[0].Previous.Type)
+
+	req = httptest.NewRequest("GET", "/matches/1/actions/3/corrections", nil)
+	rec = httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+
+	corrections = nil
+	r.Nil(json.Unmarshal(rec.Body.Bytes(), &corrections))
+	r.Len(corrections, 1)
+	r.True(corrections[0].Annulment)
+	r.Equal("Foul in the build-up", corrections[0].Reason)
+	r.False(corrections[0].Previous.Annulled)
+}
+
+func TestMatchSubstitutions(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	s.config.maxSubstitutions = 1
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "A", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(2), DisplayName: "B", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(3), DisplayName: "C", Number: 12, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(4), DisplayName: "D", Number: 19, Position: POSITION_STRIKER},
+		{PlayerID: int64(5), DisplayName: "E", Number: 25, Position: POSITION_STRIKER},
+		{PlayerID: int64(6), DisplayName: "F", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(7), DisplayName: "G", Number: 13, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), IsLocal: boolPtr(true)},
+		{LineupID: int64(2), IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1), Role: ROLE_STARTER},
+		{LineupID: int64(1), PlayerID: int64(2), Role: ROLE_STARTER},
+		{LineupID: int64(1), PlayerID: int64(3), Role: ROLE_SUBSTITUTE},
+		{LineupID: int64(1), PlayerID: int64(4), Role: ROLE_SUBSTITUTE},
+		{LineupID: int64(1), PlayerID: int64(5), Role: ROLE_UNUSED},
+		{LineupID: int64(2), PlayerID: int64(6), Role: ROLE_STARTER},
+		{LineupID: int64(2), PlayerID: int64(7), Role: ROLE_SUBSTITUTE},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Substitute a player on the bench",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(3),
+				SubstituteID: int64Ptr(4),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player coming off is not on the pitch"}`,
+		},
+		{
+			Name:   "Bring on a substitute of the other lineup",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(7),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player coming on is not a substitute of the lineup"}`,
+		},
+		{
+			Name:   "Bring on an unused player",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(5),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player coming on is not a substitute of the lineup"}`,
+		},
+		{
+			Name:   "Substitute the goalkeeper",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(3),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Exceed maximum substitutions",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(2),
+				SubstituteID: int64Ptr(4),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(70, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"lineup has reached maximum substitutions"}`,
+		},
+		{
+			Name:   "Unused player scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(5),
+				Type:     ACTION_GOAL,
+				Time:     at(75, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is not in any of the match lineups"}`,
+		},
+		{
+			Name:               "Players on the pitch before the substitution",
+			Method:             "GET",
+			Target:             "/matches/1/pitch?time=59",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"home":[{"player_id":1,"display_name":"A","number":1,"position":"POSITION_GOALKEEPER"},{"player_id":2,"display_name":"B","number":9,"position":"POSITION_STRIKER"}],"away":[{"player_id":6,"display_name":"F","number":1,"position":"POSITION_GOALKEEPER"}]}`,
+		},
+		{
+			Name:               "Players on the pitch after the substitution",
+			Method:             "GET",
+			Target:             "/matches/1/pitch?time=60",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"home":[{"player_id":2,"display_name":"B","number":9,"position":"POSITION_STRIKER"},{"player_id":3,"display_name":"C","number":12,"position":"POSITION_GOALKEEPER"}],"away":[{"player_id":6,"display_name":"F","number":1,"position":"POSITION_GOALKEEPER"}]}`,
+		},
+		{
+			Name:   "Away goalkeeper is sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(6),
+				Type:     ACTION_CARD_RED,
+				Time:     at(80, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:               "Minutes played in the match",
+			Method:             "GET",
+			Target:             "/matches/1/minutes",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"lineup_id":1,"minutes":60},{"player_id":2,"lineup_id":1,"minutes":90},{"player_id":3,"lineup_id":1,"minutes":30},{"player_id":6,"lineup_id":2,"minutes":80}]`,
+		},
+		{
+			Name:               "Stats do not count unfinished matches",
+			Method:             "GET",
+			Target:             "/players/3/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":3,"appearances":0,"starts":0,"minutes":0,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:   "Finish the match",
+			Method: "PUT",
+			Target: "/matches/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Stats of the substitute",
+			Method:             "GET",
+			Target:             "/players/3/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":3,"appearances":1,"starts":0,"minutes":30,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:               "Stats of the unused substitute",
+			Method:             "GET",
+			Target:             "/players/4/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":4,"appearances":0,"starts":0,"minutes":0,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestMatchTimeline(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 4, Position: POSITION_DEFENDER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 10, Position: POSITION_MIDDLEFIELD},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1)},
+		{LineupID: int64(1), PlayerID: int64(3)},
+		{LineupID: int64(2), PlayerID: int64(2)},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Assist without a goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_ASSIST,
+				Time:     at(5, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"assist must belong to a goal of the same lineup"}`,
+		},
+		{
+			Name:   "Home player scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(12, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Scorer assists their own goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_ASSIST,
+				Time:     at(12, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player cannot assist their own goal"}`,
+		},
+		{
+			Name:   "Away player assists the home goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				GoalID:   int64Ptr(1),
+				Type:     ACTION_ASSIST,
+				Time:     at(12, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"assist must belong to a goal of the same lineup"}`,
+		},
+		{
+			Name:   "Teammate assists the goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_ASSIST,
+				Time:     at(12, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:   "Goal assisted twice",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				GoalID:   int64Ptr(1),
+				Type:     ACTION_ASSIST,
+				Time:     at(13, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"goal has already been assisted"}`,
+		},
+		{
+			Name:   "Away player gets booked",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(45, 2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:   "Away player scores an own goal",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_GOAL_OWN,
+				Time:     at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":4}`,
+		},
+		{
+			Name:               "Timeline of the match",
+			Method:             "GET",
+			Target:             "/matches/1/timeline",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"time":"12'","action":"ACTION_GOAL","side":"home","player_id":1,"display_name":"Foo","assist":{"player_id":3,"display_name":"Baz"},"score":{"home":1,"away":0}},{"action_id":3,"time":"45+2'","action":"ACTION_CARD_YELLOW","side":"away","player_id":2,"display_name":"Bar","score":{"home":1,"away":0}},{"action_id":4,"time":"60'","action":"ACTION_GOAL_OWN","side":"away","player_id":2,"display_name":"Bar","score":{"home":2,"away":0}}]`,
+		},
+		{
+			Name:   "Annul goal",
+			Method: "DELETE",
+			Target: "/matches/1/actions/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Offside",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Timeline leaves the goal and its assist out",
+			Method:             "GET",
+			Target:             "/matches/1/timeline",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":3,"time":"45+2'","action":"ACTION_CARD_YELLOW","side":"away","player_id":2,"display_name":"Bar","score":{"home":0,"away":0}},{"action_id":4,"time":"60'","action":"ACTION_GOAL_OWN","side":"away","player_id":2,"display_name":"Bar","score":{"home":1,"away":0}}]`,
+		},
+		{
+			Name:               "Timeline with annulled events",
+			Method:             "GET",
+			Target:             "/matches/1/timeline?with-annulled=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"time":"12'","action":"ACTION_GOAL","side":"home","player_id":1,"display_name":"Foo","score":{"home":0,"away":0},"annulled":true},{"action_id":2,"time":"12'","action":"ACTION_ASSIST","side":"home","player_id":3,"display_name":"Baz","score":{"home":0,"away":0},"annulled":true},{"action_id":3,"time":"45+2'","action":"ACTION_CARD_YELLOW","side":"away","player_id":2,"display_name":"Bar","score":{"home":0,"away":0}},{"action_id":4,"time":"60'","action":"ACTION_GOAL_OWN","side":"away","player_id":2,"display_name":"Bar","score":{"home":1,"away":0}}]`,
+		},
+		{
+			Name:               "Timeline of unknown match",
+			Method:             "GET",
+			Target:             "/matches/2/timeline",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"match not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestMatchDismissals(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 4, Position: POSITION_DEFENDER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 10, Position: POSITION_MIDDLEFIELD},
+		{PlayerID: int64(4), DisplayName: "Qux", Number: 1, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1)},
+		{LineupID: int64(1), PlayerID: int64(2)},
+		{LineupID: int64(1), PlayerID: int64(3), Role: ROLE_SUBSTITUTE},
+		{LineupID: int64(2), PlayerID: int64(4)},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Home striker gets booked",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(10, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Home striker gets booked again",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:   "Home striker scores before being sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(20, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:   "Home striker scores after being sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(40, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player has been sent off"}`,
+		},
+		{
+			Name:   "Home defender scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_GOAL,
+				Time:     at(40, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":4}`,
+		},
+		{
+			Name:   "Home striker assists after being sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_ASSIST,
+				Time:     at(40, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player has been sent off"}`,
+		},
+		{
+			Name:   "Home striker is substituted after being sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(3),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(50, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player has been sent off"}`,
+		},
+		{
+			Name:               "Timeline shows the dismissal",
+			Method:             "GET",
+			Target:             "/matches/1/timeline",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"action_id":1,"time":"10'","action":"ACTION_CARD_YELLOW","side":"home","player_id":1,"display_name":"Foo","score":{"home":0,"away":0}},{"action_id":3,"time":"20'","action":"ACTION_GOAL","side":"home","player_id":1,"display_name":"Foo","score":{"home":1,"away":0}},{"action_id":2,"time":"30'","action":"ACTION_CARD_YELLOW","side":"home","player_id":1,"display_name":"Foo","score":{"home":1,"away":0}},{"time":"30'","action":"ACTION_CARD_RED","side":"home","player_id":1,"display_name":"Foo","score":{"home":1,"away":0},"derived_from":2},{"action_id":4,"time":"40'","action":"ACTION_GOAL","side":"home","player_id":2,"display_name":"Bar","score":{"home":2,"away":0}}]`,
+		},
+		{
+			Name:               "Sent off player is no longer on the pitch",
+			Method:             "GET",
+			Target:             "/matches/1/pitch",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"home":[{"player_id":2,"display_name":"Bar","number":4,"position":"POSITION_DEFENDER"}],"away":[{"player_id":4,"display_name":"Qux","number":1,"position":"POSITION_GOALKEEPER"}]}`,
+		},
+		{
+			Name:   "Booking before a later goal of the player",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(15, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":5}`,
+		},
+		{
+			Name:   "Second booking before a later goal of the player",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(35, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player has been sent off"}`,
+		},
+		{
+			Name:   "Annul the second booking",
+			Method: "DELETE",
+			Target: "/matches/1/actions/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Mistaken identity",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Home striker is substituted once the dismissal is overturned",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(3),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(50, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":7}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestMatchShootout(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(4), DisplayName: "Qux", Number: 1, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1)},
+		{LineupID: int64(1), PlayerID: int64(2)},
+		{LineupID: int64(2), PlayerID: int64(3)},
+		{LineupID: int64(2), PlayerID: int64(4)},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_FINISHED,
+	})
+	r.Nil(err)
+
+	pen := matchTime{period: PERIOD_PENALTIES}
+
+	kickoff := time.Date(2019, time.June, 1, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return kickoff }
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Penalty kick during regular time",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         at(90, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"` + "penalty kicks must be recorded at `PEN` time" + `"}`,
+		},
+		{
+			Name:   "Home striker scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(20, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Shootout on an uneven score",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(3),
+				GoalkeeperID: int64Ptr(2),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"shootout can only take place on a level score"}`,
+		},
+		{
+			Name:   "Away striker equalises",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_GOAL,
+				Time:     at(80, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:   "Goal during the shootout",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_GOAL,
+				Time:     pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"only penalty kicks and cards can be recorded during the shootout"}`,
+		},
+		{
+			Name:   "Penalty kick without a goalkeeper",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_PENALTY_SCORED,
+				Time:     pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"goalkeeper must be on the pitch for the other lineup"}`,
+		},
+		{
+			Name:   "Penalty kick against a teammate",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				GoalkeeperID: int64Ptr(2),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"goalkeeper must be on the pitch for the other lineup"}`,
+		},
+		{
+			Name:   "Home striker scores the first kick",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:   "Home side kicks twice in a row",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(2),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"lineup is not due to take the next kick"}`,
+		},
+		{
+			Name:   "Away striker kick is saved",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(3),
+				GoalkeeperID: int64Ptr(2),
+				Type:         ACTION_PENALTY_SAVED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":4}`,
+		},
+		{
+			Name:   "Home goalkeeper scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(2),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":5}`,
+		},
+		{
+			Name:   "Away goalkeeper misses",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(4),
+				GoalkeeperID: int64Ptr(2),
+				Type:         ACTION_PENALTY_MISSED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":6}`,
+		},
+		{
+			Name:   "Home striker scores again",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":7}`,
+		},
+		{
+			Name:   "Away striker kick is saved again",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(3),
+				GoalkeeperID: int64Ptr(2),
+				Type:         ACTION_PENALTY_SAVED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":8}`,
+		},
+		{
+			Name:   "Kick after the shootout is decided",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(2),
+				GoalkeeperID: int64Ptr(4),
+				Type:         ACTION_PENALTY_SCORED,
+				Time:         pen,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"shootout has already been decided"}`,
+		},
+		{
+			Name:               "Shootout of the match",
+			Method:             "GET",
+			Target:             "/matches/1/shootout",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"kicks":[{"order":1,"action_id":3,"lineup_id":1,"side":"home","kicker_id":1,"goalkeeper_id":4,"outcome":"ACTION_PENALTY_SCORED"},{"order":2,"action_id":4,"lineup_id":2,"side":"away","kicker_id":3,"goalkeeper_id":2,"outcome":"ACTION_PENALTY_SAVED"},{"order":3,"action_id":5,"lineup_id":1,"side":"home","kicker_id":2,"goalkeeper_id":4,"outcome":"ACTION_PENALTY_SCORED"},{"order":4,"action_id":6,"lineup_id":2,"side":"away","kicker_id":4,"goalkeeper_id":2,"outcome":"ACTION_PENALTY_MISSED"},{"order":5,"action_id":7,"lineup_id":1,"side":"home","kicker_id":1,"goalkeeper_id":4,"outcome":"ACTION_PENALTY_SCORED"},{"order":6,"action_id":8,"lineup_id":2,"side":"away","kicker_id":3,"goalkeeper_id":2,"outcome":"ACTION_PENALTY_SAVED"}],"score":{"home":3,"away":0},"winner_lineup_id":1}`,
+		},
+		{
+			Name:               "Shootout does not count for the score",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1,"home_lineup_id":1,"away_lineup_id":2,"status":"MATCH_STATUS_FINISHED","score":{"home":1,"away":1}}`,
+		},
+		{
+			Name:               "Shootout does not count for minutes played",
+			Method:             "GET",
+			Target:             "/matches/1/minutes",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"lineup_id":1,"minutes":90},{"player_id":2,"lineup_id":1,"minutes":90},{"player_id":3,"lineup_id":2,"minutes":90},{"player_id":4,"lineup_id":2,"minutes":90}]`,
+		},
+		{
+			Name:               "Shootout of unknown match",
+			Method:             "GET",
+			Target:             "/matches/2/shootout",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"match not found"}`,
+		},
+		{
+			Name:   "Correct the goalkeeper facing a kick",
+			Method: "PUT",
+			Target: "/matches/1/actions/8",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"goalkeeper_id": 1,
+				"changed_by":    "Referee",
+				"reason":        "Wrong goalkeeper",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Kick with the corrected goalkeeper",
+			Method:             "GET",
+			Target:             "/matches/1/actions/8",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":8,"match_id":1,"lineup_id":2,"player_id":3,"goalkeeper_id":1,"action":"ACTION_PENALTY_SAVED","time":"PEN","recorded_at":"2019-06-01T18:00:00Z"}`,
+		},
+		{
+			Name:   "Correct a kick into a booking",
+			Method: "PUT",
+			Target: "/matches/1/actions/8",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"action":     "ACTION_CARD_YELLOW",
+				"changed_by": "Referee",
+				"reason":     "Booked for time wasting, the kick was not taken",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Booking drops the goalkeeper of the kick",
+			Method:             "GET",
+			Target:             "/matches/1/actions/8",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":8,"match_id":1,"lineup_id":2,"player_id":3,"action":"ACTION_CARD_YELLOW","time":"PEN","recorded_at":"2019-06-01T18:00:00Z"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestCompetitionSubstitutions(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(competitionsTable).Insert(&competition{CompetitionID: int64(1), Name: "Cup", MaxSubstitutions: intPtr(1)})
+	r.Nil(err)
+
+	_, err = s.db.Collection(seasonsTable).Insert(&season{SeasonID: int64(1), CompetitionID: int64(1), Name: "2021", Rounds: 1})
+	r.Nil(err)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "A", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(2), DisplayName: "B", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(3), DisplayName: "C", Number: 12, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(4), DisplayName: "D", Number: 19, Position: POSITION_STRIKER},
+		{PlayerID: int64(5), DisplayName: "E", Number: 1, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), IsLocal: boolPtr(true)},
+		{LineupID: int64(2), IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1), Role: ROLE_STARTER},
+		{LineupID: int64(1), PlayerID: int64(2), Role: ROLE_STARTER},
+		{LineupID: int64(1), PlayerID: int64(3), Role: ROLE_SUBSTITUTE},
+		{LineupID: int64(1), PlayerID: int64(4), Role: ROLE_SUBSTITUTE},
+		{LineupID: int64(2), PlayerID: int64(5), Role: ROLE_STARTER},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	_, err = s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		SeasonID:     int64Ptr(1),
+		HomeLineupID: int64Ptr(1),
+		AwayLineupID: int64Ptr(2),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Substitute the goalkeeper",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				SubstituteID: int64Ptr(3),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Exceed the competition maximum substitutions",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(2),
+				SubstituteID: int64Ptr(4),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(70, 0),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"lineup has reached maximum substitutions"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type availabilityStatus uint16
+
+func (a availabilityStatus) String() string {
+	s, ok := availabilityStatus_name[int(a)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(a))
+}
+
+func (a availabilityStatus) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *availabilityStatus) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := availabilityStatus_value[s]; ok {
+		*a = availabilityStatus(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
+const (
+	AVAILABILITY_INVALID availabilityStatus = iota
+	// AVAILABILITY_AVAILABLE players have no absence covering the day. It is
+	// never recorded, only used to filter players.
+	AVAILABILITY_AVAILABLE
+	AVAILABILITY_INJURED
+	AVAILABILITY_ILL
+	AVAILABILITY_INTERNATIONAL_DUTY
+	AVAILABILITY_SUSPENDED
+)
+
+var availabilityStatus_name = map[int]string{
+	0: "AVAILABILITY_INVALID",
+	1: "AVAILABILITY_AVAILABLE",
+	2: "AVAILABILITY_INJURED",
+	3: "AVAILABILITY_ILL",
+	4: "AVAILABILITY_INTERNATIONAL_DUTY",
+	5: "AVAILABILITY_SUSPENDED",
+}
+
+var availabilityStatus_value = map[string]int{
+	"AVAILABILITY_INVALID":            0,
+	"AVAILABILITY_AVAILABLE":          1,
+	"AVAILABILITY_INJURED":            2,
+	"AVAILABILITY_ILL":                3,
+	"AVAILABILITY_INTERNATIONAL_DUTY": 4,
+	"AVAILABILITY_SUSPENDED":          5,
+}
+
+// availability is a period a player cannot be picked for, from StartDate
+// until the day before ExpectedReturn. Periods without an expected return
+// are open-ended.
+type availability struct {
+	AvailabilityID int64              `json:"availability_id,omitempty" db:"availability_id,omitempty"`
+	PlayerID       int64              `json:"player_id,omitempty" db:"player_id,omitempty"`
+	Status         availabilityStatus `json:"status,omitempty" db:"status"`
+	StartDate      date               `json:"start_date" db:"start_date"`
+	ExpectedReturn *date              `json:"expected_return,omitempty" db:"expected_return"`
+	Notes          string             `json:"notes,omitempty" db:"notes"`
+}
+
+// covers reports whether the player is unavailable on the given day.
+func (a *availability) covers(on date) bool {
+	return !on.before(a.StartDate) && (a.ExpectedReturn == nil || on.before(*a.ExpectedReturn))
+}
+
+// unavailableError is returned when an unavailable player is picked for a
+// lineup and unavailable players are blocked.
+type unavailableError struct {
+	Availability *availability
+}
+
+func (e *unavailableError) Error() string {
+	if e.Availability.ExpectedReturn == nil {
+		return fmt.Sprintf("player is unavailable (%s since %s)",
+			e.Availability.Status, e.Availability.StartDate)
+	}
+	return fmt.Sprintf("player is unavailable (%s until %s)",
+		e.Availability.Status, e.Availability.ExpectedReturn)
+}
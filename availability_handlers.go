@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func availabilityID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("availability_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("availability_id", str).Debug("Failed to parse `availability_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `availability_id`")
+		}
+
+		c.Set("availability_id", id)
+
+		return next(c)
+	}
+}
+
+func getAvailabilityID(c echo.Context) (id int64) {
+	id, _ = c.Get("availability_id").(int64)
+	return
+}
+
+const availabilityTable = "player_availability"
+
+var (
+	errAvailabilityNotFound       = errors.New("availability not found")
+	errInvalidAvailabilityStatus  = errors.New("Invalid `status` value")
+	errInvalidAvailabilityStart   = errors.New("Invalid `start_date` value")
+	errInvalidAvailabilityReturn  = errors.New("`expected_return` must be after `start_date`")
+	errInvalidAvailabilityValue   = errors.New("Invalid `availability` value")
+	errInvalidAvailabilityDate    = errors.New("Invalid `date` value")
+	errAvailabilityPlayerMismatch = errors.New("`player_id` does not match the player")
+)
+
+// check validates the period before it is stored.
+func (a *availability) check() error {
+	if a.Status == AVAILABILITY_INVALID || a.Status == AVAILABILITY_AVAILABLE {
+		return errInvalidAvailabilityStatus
+	}
+	if _, ok := availabilityStatus_name[int(a.Status)]; !ok {
+		return errInvalidAvailabilityStatus
+	}
+	if a.StartDate.IsZero() {
+		return errInvalidAvailabilityStart
+	}
+	if a.ExpectedReturn != nil && !a.StartDate.before(*a.ExpectedReturn) {
+		return errInvalidAvailabilityReturn
+	}
+	return nil
+}
+
+// unavailablePlayers returns the periods that keep the given players out on
+// the day, the one ending last for players with several. Open-ended periods
+// sort first as postgres puts nulls first in descending order.
+func unavailablePlayers(sess sqlbuilder.SQLBuilder, playerIDs []int64, on date) (map[int64]*availability, error) {
+	if len(playerIDs) == 0 {
+		return nil, nil
+	}
+
+	var periods []availability
+
+	err := sess.SelectFrom(availabilityTable).Where(db.Cond{"player_id": db.In(playerIDs)}).
+		And(db.Raw("start_date <= ? AND (expected_return IS NULL OR expected_return > ?)", on, on)).
+		OrderBy("player_id", "-expected_return", "availability_id").All(&periods)
+	if err != nil {
+		return nil, err
+	}
+
+	unavailable := map[int64]*availability{}
+	for i := range periods {
+		if _, ok := unavailable[periods[i].PlayerID]; !ok {
+			unavailable[periods[i].PlayerID] = &periods[i]
+		}
+	}
+
+	return unavailable, nil
+}
+
+func (s *server) createAvailability(c echo.Context) error {
+	req := new(availability)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure AvailabilityID is not set.
+	if req.AvailabilityID != 0 {
+		log.WithError(fmt.Errorf("availability_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.PlayerID != 0 && req.PlayerID != getPlayerID(c) {
+		log.WithError(errAvailabilityPlayerMismatch).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errAvailabilityPlayerMismatch.Error())
+	}
+
+	if err := req.check(); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	req.PlayerID = getPlayerID(c)
+
+	ret, err := s.db.Collection(availabilityTable).Insert(req)
+	if isForeignKeyViolation(err) {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to insert availability in the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting an availability")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &availability{
+		AvailabilityID: id,
+	})
+}
+
+// listAvailability returns the periods the player was or will be
+// unavailable, in the order they start.
+func (s *server) listAvailability(c echo.Context) error {
+	found := new(player)
+
+	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	periods := []availability{}
+
+	err = s.db.Collection(availabilityTable).Find("player_id", found.PlayerID).
+		OrderBy("start_date", "availability_id").All(&periods)
+	if err != nil {
+		log.WithError(err).Error("Failed to list availability from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &periods)
+}
+
+// updateAvailability changes the fields set in the request, like the
+// expected return once it is known.
+func (s *server) updateAvailability(c echo.Context) error {
+	req := new(availability)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure AvailabilityID and PlayerID are not set.
+	if req.AvailabilityID != 0 || req.PlayerID != 0 {
+		log.WithError(fmt.Errorf("availability_id or player_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	res := s.db.Collection(availabilityTable).Find("availability_id", getAvailabilityID(c)).
+		And("player_id", getPlayerID(c))
+
+	found := new(availability)
+
+	err := res.One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("availability_id", getAvailabilityID(c)).Debug("availability not found")
+		return echo.NewHTTPError(http.StatusNotFound, errAvailabilityNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve availability from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if req.Status != AVAILABILITY_INVALID {
+		found.Status = req.Status
+	}
+	if !req.StartDate.IsZero() {
+		found.StartDate = req.StartDate
+	}
+	if req.ExpectedReturn != nil {
+		found.ExpectedReturn = req.ExpectedReturn
+	}
+	if req.Notes != "" {
+		found.Notes = req.Notes
+	}
+
+	if err := found.check(); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	err = res.Update(found)
+	if err != nil {
+		log.WithError(err).Error("Failed to update availability from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteAvailability(c echo.Context) error {
+	err := s.db.Collection(availabilityTable).Find("availability_id", getAvailabilityID(c)).
+		And("player_id", getPlayerID(c)).Delete()
+	if err != nil {
+		log.WithError(err).Error("Failed to delete availability from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
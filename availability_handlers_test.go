@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayerAvailability(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(teamsTable).Insert(&team{TeamID: int64(1), Name: "Home"})
+	r.Nil(err)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), TeamID: int64Ptr(1), DisplayName: "A", Number: 4, Position: POSITION_DEFENDER},
+		{PlayerID: int64(2), TeamID: int64Ptr(1), DisplayName: "B", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(3), TeamID: int64Ptr(1), DisplayName: "C", Number: 5, Position: POSITION_DEFENDER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	_, err = s.db.Collection(lineupsTable).Insert(&lineup{LineupID: int64(1), TeamID: int64Ptr(1), IsLocal: boolPtr(true)})
+	r.Nil(err)
+
+	_, err = s.db.Collection(lineupsTable).Insert(&lineup{LineupID: int64(2), IsLocal: boolPtr(false)})
+	r.Nil(err)
+
+	_, err = s.db.Collection(lineupPlayersTable).Insert(&lineupPlayer{LineupID: int64(2), PlayerID: int64(3), TeamID: int64Ptr(1), Role: ROLE_STARTER})
+	r.Nil(err)
+
+	kickoff := time.Date(2021, 3, 10, 18, 0, 0, 0, time.UTC)
+
+	_, err = s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		Kickoff:      &kickoff,
+		Status:       MATCH_STATUS_SCHEDULED,
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "A is injured",
+			Method: "POST",
+			Target: "/players/1/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status:         AVAILABILITY_INJURED,
+				StartDate:      mustDate("2021-03-01"),
+				ExpectedReturn: datePtr("2021-03-15"),
+				Notes:          "Hamstring",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"availability_id":1}`,
+		},
+		{
+			Name:   "B is away with the national team",
+			Method: "POST",
+			Target: "/players/2/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status:         AVAILABILITY_INTERNATIONAL_DUTY,
+				StartDate:      mustDate("2021-03-08"),
+				ExpectedReturn: datePtr("2021-03-10"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"availability_id":2}`,
+		},
+		{
+			Name:   "Available is not recorded",
+			Method: "POST",
+			Target: "/players/3/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status:    AVAILABILITY_AVAILABLE,
+				StartDate: mustDate("2021-03-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`status`" + ` value"}`,
+		},
+		{
+			Name:   "Missing start date",
+			Method: "POST",
+			Target: "/players/3/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status: AVAILABILITY_ILL,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`start_date`" + ` value"}`,
+		},
+		{
+			Name:   "Return before the start",
+			Method: "POST",
+			Target: "/players/3/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status:         AVAILABILITY_ILL,
+				StartDate:      mustDate("2021-03-01"),
+				ExpectedReturn: datePtr("2021-03-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"` + "`expected_return`" + ` must be after ` + "`start_date`" + `"}`,
+		},
+		{
+			Name:               "List A availability",
+			Method:             "GET",
+			Target:             "/players/1/availability",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"availability_id":1,"player_id":1,"status":"AVAILABILITY_INJURED","start_date":"2021-03-01","expected_return":"2021-03-15","notes":"Hamstring"}]`,
+		},
+		{
+			Name:               "List unknown player availability",
+			Method:             "GET",
+			Target:             "/players/9/availability",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player not found"}`,
+		},
+		{
+			Name:               "Injured on match day",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_INJURED&date=2021-03-10",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"team_id":1,"display_name":"A","number":4,"position":"POSITION_DEFENDER"}]`,
+		},
+		{
+			Name:               "Available on match day",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_AVAILABLE&date=2021-03-10",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":2,"team_id":1,"display_name":"B","number":9,"position":"POSITION_STRIKER"},{"player_id":3,"team_id":1,"display_name":"C","number":5,"position":"POSITION_DEFENDER"}]`,
+		},
+		{
+			Name:               "On international duty the day before",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_INTERNATIONAL_DUTY&date=2021-03-09",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":2,"team_id":1,"display_name":"B","number":9,"position":"POSITION_STRIKER"}]`,
+		},
+		{
+			Name:               "Invalid availability",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_BORED",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`availability`" + ` value"}`,
+		},
+		{
+			Name:               "Invalid date",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_INJURED&date=tomorrow",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`date`" + ` value"}`,
+		},
+		{
+			Name:   "A is picked with a warning",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"warnings":[{"player_id":1,"message":"player is unavailable (AVAILABILITY_INJURED until 2021-03-15)"}]}`,
+		},
+		{
+			Name:   "B is back for the match",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "A recovers early",
+			Method: "PUT",
+			Target: "/players/1/availability/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				ExpectedReturn: datePtr("2021-03-09"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Unknown availability",
+			Method: "PUT",
+			Target: "/players/2/availability/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Notes: "Fine",
+			},
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"availability not found"}`,
+		},
+		{
+			Name:               "Everyone available on match day",
+			Method:             "GET",
+			Target:             "/players?availability=AVAILABILITY_AVAILABLE&date=2021-03-10",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"team_id":1,"display_name":"A","number":4,"position":"POSITION_DEFENDER"},{"player_id":2,"team_id":1,"display_name":"B","number":9,"position":"POSITION_STRIKER"},{"player_id":3,"team_id":1,"display_name":"C","number":5,"position":"POSITION_DEFENDER"}]`,
+		},
+		{
+			Name:               "Delete B availability",
+			Method:             "DELETE",
+			Target:             "/players/2/availability/2",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "List B availability",
+			Method:             "GET",
+			Target:             "/players/2/availability",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:   "C is ill",
+			Method: "POST",
+			Target: "/players/3/availability",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: availability{
+				Status:    AVAILABILITY_ILL,
+				StartDate: mustDate("2021-03-09"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"availability_id":3}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+
+	// Unavailable players are kept out once blocking is enabled.
+	s.config.blockUnavailable = true
+
+	body, err := json.Marshal(lineupPlayer{PlayerID: int64(3)})
+	r.Nil(err)
+	req := httptest.NewRequest("POST", "/lineups/1/players", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusUnprocessableEntity, rec.Code)
+	r.Equal(`{"message":"player is unavailable (AVAILABILITY_ILL since 2021-03-09)"}`, strings.TrimRight(rec.Body.String(), "\n"))
+
+	// Nor can they be brought in by attaching a lineup to the match.
+	body, err = json.Marshal(match{AwayLineupID: int64Ptr(2)})
+	r.Nil(err)
+	req = httptest.NewRequest("PUT", "/matches/1", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec = httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusUnprocessableEntity, rec.Code)
+	r.Equal(`{"message":"player is unavailable (AVAILABILITY_ILL since 2021-03-09)"}`, strings.TrimRight(rec.Body.String(), "\n"))
+}
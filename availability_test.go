@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func datePtr(s string) *date {
+	d := mustDate(s)
+	return &d
+}
+
+func TestAvailabilityCovers(t *testing.T) {
+	injury := &availability{
+		Status:         AVAILABILITY_INJURED,
+		StartDate:      mustDate("2021-03-01"),
+		ExpectedReturn: datePtr("2021-03-15"),
+	}
+	illness := &availability{
+		Status:    AVAILABILITY_ILL,
+		StartDate: mustDate("2021-03-01"),
+	}
+
+	for _, tc := range []struct {
+		Name         string
+		Availability *availability
+		On           string
+		Expected     bool
+	}{
+		{Name: "Before the start", Availability: injury, On: "2021-02-28", Expected: false},
+		{Name: "On the start", Availability: injury, On: "2021-03-01", Expected: true},
+		{Name: "Day before the return", Availability: injury, On: "2021-03-14", Expected: true},
+		{Name: "On the return", Availability: injury, On: "2021-03-15", Expected: false},
+		{Name: "Open-ended", Availability: illness, On: "2022-01-01", Expected: true},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.Expected, tc.Availability.covers(mustDate(tc.On)))
+		})
+	}
+}
+
+func TestLineupRestrictions(t *testing.T) {
+	r := require.New(t)
+
+	restrictions := &lineupRestrictions{
+		suspended: map[int64]*suspension{
+			1: {PlayerID: 1, Reason: SUSPENSION_REASON_RED_CARD, MatchID: 3, Matches: 1, Remaining: 1},
+		},
+		unavailable: map[int64]*availability{
+			2: {PlayerID: 2, Status: AVAILABILITY_INJURED, StartDate: mustDate("2021-03-01"), ExpectedReturn: datePtr("2021-03-15")},
+		},
+	}
+
+	warning, err := restrictions.check(1)
+	r.Nil(warning)
+	r.IsType(&suspendedError{}, err)
+
+	warning, err = restrictions.check(2)
+	r.Nil(err)
+	r.Equal(&lineupWarning{PlayerID: 2, Message: "player is unavailable (AVAILABILITY_INJURED until 2021-03-15)"}, warning)
+
+	warning, err = restrictions.check(3)
+	r.Nil(warning)
+	r.Nil(err)
+
+	restrictions.block = true
+
+	warning, err = restrictions.check(2)
+	r.Nil(warning)
+	r.EqualError(err, "player is unavailable (AVAILABILITY_INJURED until 2021-03-15)")
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type period uint16
+
+func (p period) String() string {
+	s, ok := period_name[int(p)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(p))
+}
+
+func (p period) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *period) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := period_value[s]; ok {
+		*p = period(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
+const (
+	PERIOD_INVALID period = iota
+	PERIOD_FIRST_HALF
+	PERIOD_SECOND_HALF
+	PERIOD_EXTRA_TIME_FIRST_HALF
+	PERIOD_EXTRA_TIME_SECOND_HALF
+	PERIOD_PENALTIES
+)
+
+var period_name = map[int]string{
+	0: "PERIOD_INVALID",
+	1: "PERIOD_FIRST_HALF",
+	2: "PERIOD_SECOND_HALF",
+	3: "PERIOD_EXTRA_TIME_FIRST_HALF",
+	4: "PERIOD_EXTRA_TIME_SECOND_HALF",
+	5: "PERIOD_PENALTIES",
+}
+
+var period_value = map[string]int{
+	"PERIOD_INVALID":                0,
+	"PERIOD_FIRST_HALF":             1,
+	"PERIOD_SECOND_HALF":            2,
+	"PERIOD_EXTRA_TIME_FIRST_HALF":  3,
+	"PERIOD_EXTRA_TIME_SECOND_HALF": 4,
+	"PERIOD_PENALTIES":              5,
+}
+
+// periodEnd is the last regular minute of each period, after which only
+// stoppage time is played.
+var periodEnd = map[period]uint16{
+	PERIOD_FIRST_HALF:             45,
+	PERIOD_SECOND_HALF:            90,
+	PERIOD_EXTRA_TIME_FIRST_HALF:  105,
+	PERIOD_EXTRA_TIME_SECOND_HALF: 120,
+}
+
+// maxStoppage is the longest stoppage time that fits in the two digits
+// reserved for it by matchTime.key.
+const maxStoppage = 99
+
+// matchTime is a moment of a match as shown on the scoreboard, e.g. 45+3'
+// is the third minute of stoppage time of the first half. It is stored as a
+// single integer that sorts in the same order as the moments it encodes.
+type matchTime struct {
+	period   period
+	minute   uint16
+	stoppage uint16
+}
+
+// newMatchTime returns the moment at the given minute and stoppage minute,
+// inferring the period it belongs to.
+func newMatchTime(minute, stoppage uint16) (matchTime, error) {
+	t := matchTime{minute: minute, stoppage: stoppage}
+
+	for p := PERIOD_FIRST_HALF; p <= PERIOD_EXTRA_TIME_SECOND_HALF; p++ {
+		if minute <= periodEnd[p] {
+			t.period = p
+			break
+		}
+	}
+
+	if minute == 0 || t.period == PERIOD_INVALID {
+		return matchTime{}, fmt.Errorf("Invalid minute %d", minute)
+	}
+
+	if stoppage > 0 && minute != periodEnd[t.period] {
+		return matchTime{}, fmt.Errorf("Stoppage time can only be added to the end of a period")
+	}
+
+	if stoppage > maxStoppage {
+		return matchTime{}, fmt.Errorf("Stoppage time cannot exceed %d minutes", maxStoppage)
+	}
+
+	return t, nil
+}
+
+func (t matchTime) IsZero() bool {
+	return t.period == PERIOD_INVALID
+}
+
+func (t matchTime) key() int64 {
+	return int64(t.period)*100000 + int64(t.minute)*100 + int64(t.stoppage)
+}
+
+// before reports whether t happens before o.
+func (t matchTime) before(o matchTime) bool {
+	return t.key() < o.key()
+}
+
+func (t matchTime) String() string {
+	switch {
+	case t.period == PERIOD_PENALTIES:
+		return "PEN"
+	case t.stoppage > 0:
+		return fmt.Sprintf("%d+%d'", t.minute, t.stoppage)
+	default:
+		return fmt.Sprintf("%d'", t.minute)
+	}
+}
+
+func (t matchTime) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *matchTime) UnmarshalText(b []byte) error {
+	s := strings.TrimSuffix(strings.TrimSpace(string(b)), "'")
+	if s == "PEN" {
+		*t = matchTime{period: PERIOD_PENALTIES}
+		return nil
+	}
+
+	parts := strings.SplitN(s, "+", 2)
+
+	minute, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil {
+		return fmt.Errorf("Could not parse %s", b)
+	}
+
+	var stoppage uint64
+	if len(parts) == 2 {
+		stoppage, err = strconv.ParseUint(parts[1], 10, 16)
+		if err != nil || stoppage == 0 {
+			return fmt.Errorf("Could not parse %s", b)
+		}
+	}
+
+	parsed, err := newMatchTime(uint16(minute), uint16(stoppage))
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+	return nil
+}
+
+func (t matchTime) Value() (driver.Value, error) {
+	return t.key(), nil
+}
+
+func (t *matchTime) Scan(src interface{}) error {
+	key, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("Failed to scan %v (%T) as a match time", src, src)
+	}
+
+	*t = matchTime{
+		period:   period(key / 100000),
+		minute:   uint16(key % 100000 / 100),
+		stoppage: uint16(key % 100),
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTime(t *testing.T) {
+	for _, tc := range []struct {
+		Name           string
+		Text           string
+		ExpectedError  bool
+		ExpectedPeriod period
+		ExpectedText   string
+	}{
+		{
+			Name:           "First minute",
+			Text:           "1'",
+			ExpectedPeriod: PERIOD_FIRST_HALF,
+			ExpectedText:   "1'",
+		},
+		{
+			Name:           "First half stoppage time",
+			Text:           "45+3'",
+			ExpectedPeriod: PERIOD_FIRST_HALF,
+			ExpectedText:   "45+3'",
+		},
+		{
+			Name:           "Second half without apostrophe",
+			Text:           "46",
+			ExpectedPeriod: PERIOD_SECOND_HALF,
+			ExpectedText:   "46'",
+		},
+		{
+			Name:           "Second half stoppage time",
+			Text:           "90+4'",
+			ExpectedPeriod: PERIOD_SECOND_HALF,
+			ExpectedText:   "90+4'",
+		},
+		{
+			Name:           "Extra time",
+			Text:           "105+1'",
+			ExpectedPeriod: PERIOD_EXTRA_TIME_FIRST_HALF,
+			ExpectedText:   "105+1'",
+		},
+		{
+			Name:           "Penalties",
+			Text:           "PEN",
+			ExpectedPeriod: PERIOD_PENALTIES,
+			ExpectedText:   "PEN",
+		},
+		{
+			Name:          "Stoppage time in the middle of a half",
+			Text:          "60+2'",
+			ExpectedError: true,
+		},
+		{
+			Name:          "Minute zero",
+			Text:          "0'",
+			ExpectedError: true,
+		},
+		{
+			Name:          "Beyond extra time",
+			Text:          "121'",
+			ExpectedError: true,
+		},
+		{
+			Name:           "Longest stoppage time",
+			Text:           "90+99'",
+			ExpectedPeriod: PERIOD_SECOND_HALF,
+			ExpectedText:   "90+99'",
+		},
+		{
+			Name:          "Stoppage time too long",
+			Text:          "90+100'",
+			ExpectedError: true,
+		},
+		{
+			Name:          "Garbage",
+			Text:          "foo",
+			ExpectedError: true,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var mt matchTime
+			err := mt.UnmarshalText([]byte(tc.Text))
+			if tc.ExpectedError {
+				r.NotNil(err)
+				return
+			}
+			r.Nil(err)
+			r.Equal(tc.ExpectedPeriod, mt.period)
+
+			text, err := mt.MarshalText()
+			r.Nil(err)
+			r.Equal(tc.ExpectedText, string(text))
+
+			value, err := mt.Value()
+			r.Nil(err)
+
+			var scanned matchTime
+			r.Nil(scanned.Scan(value))
+			r.Equal(mt, scanned)
+		})
+	}
+}
+
+func TestMatchTimeOrder(t *testing.T) {
+	r := require.New(t)
+
+	times := []matchTime{at(90, 4), at(46, 0), at(45, 3), at(120, 0), at(45, 0), {period: PERIOD_PENALTIES}, at(90, 0)}
+	sort.Slice(times, func(i, j int) bool {
+		return times[i].before(times[j])
+	})
+
+	var texts []string
+	for _, mt := range times {
+		texts = append(texts, mt.String())
+	}
+
+	r.Equal([]string{"45'", "45+3'", "46'", "90'", "90+4'", "120'", "PEN"}, texts)
+}
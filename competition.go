@@ -0,0 +1,45 @@
+package main
+
+import "time"
+
+// competition is a league run season after season. MaxSubstitutions is how
+// many substitutions a lineup can make in its matches, the
+// `max-substitutions` flag when not set.
+type competition struct {
+	CompetitionID    int64  `json:"competition_id,omitempty" db:"competition_id,omitempty"`
+	Name             string `json:"name,omitempty" db:"name,omitempty"`
+	MaxSubstitutions *int   `json:"max_substitutions,omitempty" db:"max_substitutions,omitempty"`
+}
+
+// season is an edition of a competition played by the teams registered to
+// it. Rounds is how many times every team plays each other, two for a
+// double round-robin. Tiebreakers rank the teams level on points in its
+// standings, defaultTiebreakers when not set.
+type season struct {
+	SeasonID      int64       `json:"season_id,omitempty" db:"season_id,omitempty"`
+	CompetitionID int64       `json:"competition_id,omitempty" db:"competition_id,omitempty"`
+	Name          string      `json:"name,omitempty" db:"name,omitempty"`
+	Rounds        int         `json:"rounds,omitempty" db:"rounds,omitempty"`
+	Tiebreakers   tiebreakers `json:"tiebreakers,omitempty" db:"tiebreakers,omitempty"`
+}
+
+const (
+	defaultSeasonRounds = 2
+	// maxSeasonRounds bounds the number of matches generated for a season.
+	maxSeasonRounds = 4
+)
+
+type seasonTeam struct {
+	SeasonID int64 `json:"season_id,omitempty" db:"season_id,omitempty"`
+	TeamID   int64 `json:"team_id,omitempty" db:"team_id,omitempty"`
+}
+
+// fixtureSchedule sets when generated fixtures are played. Kickoff is the
+// kickoff of the first matchday, following ones are played IntervalDays
+// apart. Without it fixtures are generated with no kickoff.
+type fixtureSchedule struct {
+	Kickoff      *time.Time `json:"kickoff,omitempty"`
+	IntervalDays int        `json:"interval_days,omitempty"`
+}
+
+const defaultIntervalDays = 7
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+)
+
+func competitionID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("competition_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("competition_id", str).Debug("Failed to parse `competition_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `competition_id`")
+		}
+
+		c.Set("competition_id", id)
+
+		return next(c)
+	}
+}
+
+func getCompetitionID(c echo.Context) (id int64) {
+	id, _ = c.Get("competition_id").(int64)
+	return
+}
+
+const competitionsTable = "competitions"
+
+var (
+	errCompetitionNotFound     = errors.New("competition not found")
+	errInvalidMaxSubstitutions = errors.New("Invalid `max_substitutions` value")
+	errInvalidCompetitionValue = errors.New("Invalid `competition_id` value")
+)
+
+func (s *server) createCompetition(c echo.Context) error {
+	req := new(competition)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure CompetitionID is not set.
+	if req.CompetitionID != 0 {
+		log.WithError(fmt.Errorf("competition_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.MaxSubstitutions != nil && *req.MaxSubstitutions < 0 {
+		log.WithError(errInvalidMaxSubstitutions).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidMaxSubstitutions.Error())
+	}
+
+	ret, err := s.db.Collection(competitionsTable).Insert(req)
+	if err != nil {
+		log.WithError(err).Error("Failed to insert competition in the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting a competition")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &competition{
+		CompetitionID: id,
+	})
+}
+
+func (s *server) getCompetition(c echo.Context) error {
+	found := new(competition)
+
+	err := s.db.Collection(competitionsTable).Find("competition_id", getCompetitionID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("competition_id", getCompetitionID(c)).Debug("competition not found")
+		return echo.NewHTTPError(http.StatusNotFound, errCompetitionNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve competition from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+func (s *server) listCompetitions(c echo.Context) error {
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	var competitions []competition
+
+	err = s.db.Collection(competitionsTable).Find().OrderBy("competition_id").
+		Paginate(limit).Page(page).All(&competitions)
+	if err != nil {
+		log.WithError(err).Error("Failed to list competitions from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &competitions)
+}
+
+func (s *server) updateCompetition(c echo.Context) error {
+	req := new(competition)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	// Ensure CompetitionID is not set.
+	if req.CompetitionID != 0 {
+		log.WithError(fmt.Errorf("competition_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if req.MaxSubstitutions != nil && *req.MaxSubstitutions < 0 {
+		log.WithError(errInvalidMaxSubstitutions).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidMaxSubstitutions.Error())
+	}
+
+	err := s.db.Collection(competitionsTable).Find("competition_id", getCompetitionID(c)).Update(req)
+	if err != nil {
+		log.WithError(err).Error("Failed to update competition from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteCompetition(c echo.Context) error {
+	err := s.db.Collection(competitionsTable).Find("competition_id", getCompetitionID(c)).Delete()
+	if err != nil {
+		log.WithError(err).Error("Failed to delete competition from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompetitionCRUD(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "`competition_id` explictly set on create",
+			Method: "POST",
+			Target: "/competitions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				CompetitionID: int64(1),
+				Name:          "Liga",
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Create first competition",
+			Method: "POST",
+			Target: "/competitions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				Name: "Liga",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"competition_id":1}`,
+		},
+		{
+			Name:   "Create second competition",
+			Method: "POST",
+			Target: "/competitions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				Name: "Copa",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"competition_id":2}`,
+		},
+		{
+			Name:               "List competitions",
+			Method:             "GET",
+			Target:             "/competitions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"competition_id":1,"name":"Liga"},{"competition_id":2,"name":"Copa"}]`,
+		},
+		{
+			Name:   "Rename second competition",
+			Method: "PUT",
+			Target: "/competitions/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				Name:             "Supercopa",
+				MaxSubstitutions: intPtr(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Negative maximum substitutions",
+			Method: "PUT",
+			Target: "/competitions/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				MaxSubstitutions: intPtr(-1),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`max_substitutions`" + ` value"}`,
+		},
+		{
+			Name:               "Get second competition",
+			Method:             "GET",
+			Target:             "/competitions/2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"competition_id":2,"name":"Supercopa","max_substitutions":3}`,
+		},
+		{
+			Name:   "Create a season of the second competition",
+			Method: "POST",
+			Target: "/competitions/2/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name: "2020",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"season_id":1}`,
+		},
+		{
+			Name:               "Delete second competition",
+			Method:             "DELETE",
+			Target:             "/competitions/2",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Attempt to get deleted competition",
+			Method:             "GET",
+			Target:             "/competitions/2",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"competition not found"}`,
+		},
+		{
+			Name:               "Seasons of the deleted competition are deleted",
+			Method:             "GET",
+			Target:             "/seasons/1",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"season not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
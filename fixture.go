@@ -0,0 +1,67 @@
+package main
+
+// fixture is a pairing of two teams on a matchday.
+type fixture struct {
+	HomeTeamID int64
+	AwayTeamID int64
+}
+
+// roundRobin schedules the matchdays of a league where every team plays
+// every other team once per round, following the circle method: the first
+// team stays fixed while the rest rotate around it. Venues alternate from
+// one matchday to the next as far as possible and even rounds mirror the
+// odd ones, so over a double round-robin each team plays as many matches at
+// home as away. With an odd number of teams one of them rests on each
+// matchday.
+func roundRobin(teams []int64, rounds int) [][]fixture {
+	ids := append([]int64(nil), teams...)
+	if len(ids)%2 == 1 {
+		// 0 stands for the bye. Keeping it fixed lets every other team
+		// alternate venues between the matchdays it rests on.
+		ids = append([]int64{0}, ids...)
+	}
+
+	n := len(ids)
+	if n < 2 || rounds < 1 {
+		return nil
+	}
+
+	cycle := make([][]fixture, 0, n-1)
+	for r := 0; r < n-1; r++ {
+		var day []fixture
+		for i := 0; i < n/2; i++ {
+			home, away := ids[i], ids[n-1-i]
+			// The fixed team alternates venues every matchday; the rest do
+			// so as they move along the circle.
+			if (i == 0 && r%2 == 1) || (i > 0 && i%2 == 1) {
+				home, away = away, home
+			}
+			if home == 0 || away == 0 {
+				continue
+			}
+			day = append(day, fixture{HomeTeamID: home, AwayTeamID: away})
+		}
+		cycle = append(cycle, day)
+
+		// Rotate every team but the first one position clockwise.
+		last := ids[n-1]
+		copy(ids[2:], ids[1:n-1])
+		ids[1] = last
+	}
+
+	matchdays := make([][]fixture, 0, rounds*len(cycle))
+	for round := 0; round < rounds; round++ {
+		for _, day := range cycle {
+			if round%2 == 1 {
+				mirrored := make([]fixture, len(day))
+				for i, f := range day {
+					mirrored[i] = fixture{HomeTeamID: f.AwayTeamID, AwayTeamID: f.HomeTeamID}
+				}
+				day = mirrored
+			}
+			matchdays = append(matchdays, day)
+		}
+	}
+
+	return matchdays
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundRobin(t *testing.T) {
+	for _, tc := range []struct {
+		Name              string
+		Teams             int
+		Rounds            int
+		ExpectedMatchdays int
+	}{
+		{
+			Name:              "Two teams",
+			Teams:             2,
+			Rounds:            2,
+			ExpectedMatchdays: 2,
+		},
+		{
+			Name:              "Even number of teams",
+			Teams:             6,
+			Rounds:            2,
+			ExpectedMatchdays: 10,
+		},
+		{
+			Name:              "Odd number of teams",
+			Teams:             5,
+			Rounds:            2,
+			ExpectedMatchdays: 10,
+		},
+		{
+			Name:              "Single round",
+			Teams:             8,
+			Rounds:            1,
+			ExpectedMatchdays: 7,
+		},
+		{
+			Name:              "Four rounds",
+			Teams:             20,
+			Rounds:            4,
+			ExpectedMatchdays: 76,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var teams []int64
+			for i := 1; i <= tc.Teams; i++ {
+				teams = append(teams, int64(i))
+			}
+
+			matchdays := roundRobin(teams, tc.Rounds)
+			r.Len(matchdays, tc.ExpectedMatchdays)
+
+			meetings := map[fixture]int{}
+			home := map[int64]int{}
+			away := map[int64]int{}
+			for _, day := range matchdays {
+				// Odd number of teams leave one of them resting.
+				r.Len(day, tc.Teams/2)
+
+				played := map[int64]bool{}
+				for _, f := range day {
+					r.NotEqual(f.HomeTeamID, f.AwayTeamID)
+					r.False(played[f.HomeTeamID])
+					r.False(played[f.AwayTeamID])
+					played[f.HomeTeamID] = true
+					played[f.AwayTeamID] = true
+
+					meetings[f]++
+					home[f.HomeTeamID]++
+					away[f.AwayTeamID]++
+				}
+			}
+
+			for _, a := range teams {
+				for _, b := range teams {
+					if a == b {
+						continue
+					}
+					n := meetings[fixture{HomeTeamID: a, AwayTeamID: b}] + meetings[fixture{HomeTeamID: b, AwayTeamID: a}]
+					r.Equal(tc.Rounds, n)
+				}
+
+				if tc.Rounds%2 == 0 {
+					r.Equal(home[a], away[a])
+				} else {
+					r.InDelta(home[a], away[a], 1)
+				}
+			}
+		})
+	}
+}
+
+func TestRoundRobinAlternatesVenues(t *testing.T) {
+	r := require.New(t)
+
+	for _, n := range []int{4, 5, 10, 11} {
+		var teams []int64
+		for i := 1; i <= n; i++ {
+			teams = append(teams, int64(i))
+		}
+
+		venues := map[int64]string{}
+		for _, day := range roundRobin(teams, 1) {
+			for _, f := range day {
+				venues[f.HomeTeamID] += "H"
+				venues[f.AwayTeamID] += "A"
+			}
+		}
+
+		// Every team breaks the alternation of venues at most once.
+		for id, v := range venues {
+			breaks := 0
+			for i := 1; i < len(v); i++ {
+				if v[i] == v[i-1] {
+					breaks++
+				}
+			}
+			r.True(breaks <= 1, "team %d plays %s", id, v)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// formation is the ID of an entry of the formation catalogue, zero for
+// lineups without a formation. Clients refer to formations by name, so it
+// is resolved through the catalogue.
+type formation int64
+
+// Lineups without a formation store none.
+func (f formation) Value() (driver.Value, error) {
+	if f == FORMATION_INVALID {
+		return nil, nil
+	}
+	return int64(f), nil
+}
+
+func (f *formation) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*f = FORMATION_INVALID
+	case int64:
+		*f = formation(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a formation", src)
+	}
+	return nil
+}
+
+// The formations that used to be hard-coded keep their IDs and names, they
+// are seeded into the catalogue and cannot be changed.
+const (
+	FORMATION_INVALID formation = iota
+	FORMATION_FOUR_FOUR_TWO
+	FORMATION_FOUR_THREE_THREE
+	FORMATION_THREE_FOUR_THREE
+)
+
+// formationSlots is how many players a formation needs per position.
+type formationSlots map[position]int
+
+// size is how many players the formation takes.
+func (s formationSlots) size() int {
+	n := 0
+	for _, count := range s {
+		n += count
+	}
+	return n
+}
+
+func (s formationSlots) valid() bool {
+	for pos, count := range s {
+		if _, ok := position_name[int(pos)]; !ok || pos == POSITION_INVALID || count <= 0 {
+			return false
+		}
+	}
+	return s.size() == 11
+}
+
+func (s formationSlots) Value() (driver.Value, error) {
+	b, err := json.Marshal(map[position]int(s))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (s *formationSlots) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into formation slots", src)
+	}
+	return json.Unmarshal(b, (*map[position]int)(s))
+}
+
+// formationSpot is where a player of the formation stands on the pitch, X
+// from the left touchline and Y from their own goal line, both in percent.
+type formationSpot struct {
+	Position position `json:"position"`
+	X        float64  `json:"x"`
+	Y        float64  `json:"y"`
+}
+
+// formationLayout places every slot of a formation on the pitch.
+type formationLayout []formationSpot
+
+// fits reports whether the layout places exactly the slots of the formation.
+func (l formationLayout) fits(slots formationSlots) bool {
+	count := map[position]int{}
+	for _, spot := range l {
+		if spot.X < 0 || spot.X > 100 || spot.Y < 0 || spot.Y > 100 {
+			return false
+		}
+		count[spot.Position]++
+	}
+
+	if len(count) != len(slots) {
+		return false
+	}
+	for pos, n := range slots {
+		if count[pos] != n {
+			return false
+		}
+	}
+	return true
+}
+
+func (l formationLayout) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]formationSpot(l))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (l *formationLayout) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into formation layout", src)
+	}
+	return json.Unmarshal(b, (*[]formationSpot)(l))
+}
+
+// formationEntry is a formation of the catalogue.
+type formationEntry struct {
+	FormationID int64           `json:"formation_id,omitempty" db:"formation_id,omitempty"`
+	Name        string          `json:"name,omitempty" db:"name,omitempty"`
+	Slots       formationSlots  `json:"slots,omitempty" db:"slots"`
+	Layout      formationLayout `json:"layout,omitempty" db:"layout"`
+}
+
+var (
+	errInvalidFormationName   = errors.New("Invalid `name` value")
+	errInvalidFormationSlots  = errors.New("`slots` must place 11 players on valid positions")
+	errInvalidFormationLayout = errors.New("`layout` must place every slot within the pitch")
+)
+
+func (e *formationEntry) check() error {
+	if e.Name == "" || e.Name == "FORMATION_INVALID" {
+		return errInvalidFormationName
+	}
+	// Numeric names would read like the IDs of unknown formations.
+	if _, err := strconv.Atoi(e.Name); err == nil {
+		return errInvalidFormationName
+	}
+	if !e.Slots.valid() {
+		return errInvalidFormationSlots
+	}
+	if !e.Layout.fits(e.Slots) {
+		return errInvalidFormationLayout
+	}
+	return nil
+}
+
+// legacy reports whether the entry is one of the formations that used to be
+// hard-coded.
+func (e *formationEntry) legacy() bool {
+	return e.FormationID > 0 && e.FormationID <= int64(FORMATION_THREE_FOUR_THREE)
+}
+
+var legacyFormations = []formationEntry{
+	{
+		FormationID: int64(FORMATION_FOUR_FOUR_TWO),
+		Name:        "FORMATION_FOUR_FOUR_TWO",
+		Slots: formationSlots{
+			POSITION_GOALKEEPER:  1,
+			POSITION_DEFENDER:    4,
+			POSITION_LEFT_WING:   1,
+			POSITION_RIGHT_WING:  1,
+			POSITION_MIDDLEFIELD: 2,
+			POSITION_STRIKER:     2,
+		},
+		Layout: formationLayout{
+			{POSITION_GOALKEEPER, 50, 5},
+			{POSITION_DEFENDER, 15, 25},
+			{POSITION_DEFENDER, 38, 22},
+			{POSITION_DEFENDER, 62, 22},
+			{POSITION_DEFENDER, 85, 25},
+			{POSITION_LEFT_WING, 15, 55},
+			{POSITION_MIDDLEFIELD, 38, 50},
+			{POSITION_MIDDLEFIELD, 62, 50},
+			{POSITION_RIGHT_WING, 85, 55},
+			{POSITION_STRIKER, 38, 80},
+			{POSITION_STRIKER, 62, 80},
+		},
+	},
+	{
+		FormationID: int64(FORMATION_FOUR_THREE_THREE),
+		Name:        "FORMATION_FOUR_THREE_THREE",
+		Slots: formationSlots{
+			POSITION_GOALKEEPER:  1,
+			POSITION_DEFENDER:    4,
+			POSITION_MIDDLEFIELD: 3,
+			POSITION_LEFT_WING:   1,
+			POSITION_RIGHT_WING:  1,
+			POSITION_STRIKER:     1,
+		},
+		Layout: formationLayout{
+			{POSITION_GOALKEEPER, 50, 5},
+			{POSITION_DEFENDER, 15, 25},
+			{POSITION_DEFENDER, 38, 22},
+			{POSITION_DEFENDER, 62, 22},
+			{POSITION_DEFENDER, 85, 25},
+			{POSITION_MIDDLEFIELD, 30, 50},
+			{POSITION_MIDDLEFIELD, 50, 45},
+			{POSITION_MIDDLEFIELD, 70, 50},
+			{POSITION_LEFT_WING, 15, 78},
+			{POSITION_STRIKER, 50, 85},
+			{POSITION_RIGHT_WING, 85, 78},
+		},
+	},
+	{
+		FormationID: int64(FORMATION_THREE_FOUR_THREE),
+		Name:        "FORMATION_THREE_FOUR_THREE",
+		Slots: formationSlots{
+			POSITION_GOALKEEPER:  1,
+			POSITION_DEFENDER:    3,
+			POSITION_MIDDLEFIELD: 4,
+			POSITION_LEFT_WING:   1,
+			POSITION_RIGHT_WING:  1,
+			POSITION_STRIKER:     1,
+		},
+		Layout: formationLayout{
+			{POSITION_GOALKEEPER, 50, 5},
+			{POSITION_DEFENDER, 25, 22},
+			{POSITION_DEFENDER, 50, 20},
+			{POSITION_DEFENDER, 75, 22},
+			{POSITION_MIDDLEFIELD, 15, 50},
+			{POSITION_MIDDLEFIELD, 38, 48},
+			{POSITION_MIDDLEFIELD, 62, 48},
+			{POSITION_MIDDLEFIELD, 85, 50},
+			{POSITION_LEFT_WING, 20, 78},
+			{POSITION_STRIKER, 50, 85},
+			{POSITION_RIGHT_WING, 80, 78},
+		},
+	},
+}
+
+// formationCatalogue keeps the formations in memory so they can be resolved
+// by ID and name without a query each time. Formations missing from it are
+// looked up in the store, and it is loaded again whenever any server
+// instance changes the stored ones.
+type formationCatalogue struct {
+	mu     sync.RWMutex
+	byID   map[formation]*formationEntry
+	byName map[string]formation
+}
+
+func newFormationCatalogue(entries []formationEntry) *formationCatalogue {
+	c := &formationCatalogue{}
+	c.set(entries)
+	return c
+}
+
+func (c *formationCatalogue) set(entries []formationEntry) {
+	byID := make(map[formation]*formationEntry, len(entries))
+	byName := make(map[string]formation, len(entries))
+	for i := range entries {
+		e := entries[i]
+		byID[formation(e.FormationID)] = &e
+		byName[e.Name] = formation(e.FormationID)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.byID, c.byName = byID, byName
+}
+
+func (c *formationCatalogue) add(e *formationEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.byID[formation(e.FormationID)]; ok {
+		delete(c.byName, old.Name)
+	}
+	c.byID[formation(e.FormationID)] = e
+	c.byName[e.Name] = formation(e.FormationID)
+}
+
+// find returns the stored formation matching the condition, caching it.
+func (c *formationCatalogue) find(sess sqlbuilder.SQLBuilder, cond db.Cond) (*formationEntry, error) {
+	found := new(formationEntry)
+
+	err := sess.SelectFrom(formationsTable).Where(cond).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errFormationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.add(found)
+
+	return found, nil
+}
+
+// get returns the formation with the given ID.
+func (c *formationCatalogue) get(sess sqlbuilder.SQLBuilder, f formation) (*formationEntry, error) {
+	c.mu.RLock()
+	entry, ok := c.byID[f]
+	c.mu.RUnlock()
+	if ok {
+		return entry, nil
+	}
+	return c.find(sess, db.Cond{"formation_id": int64(f)})
+}
+
+// lookup returns the ID of the formation with the given name.
+func (c *formationCatalogue) lookup(sess sqlbuilder.SQLBuilder, name string) (formation, error) {
+	c.mu.RLock()
+	f, ok := c.byName[name]
+	c.mu.RUnlock()
+	if ok {
+		return f, nil
+	}
+	entry, err := c.find(sess, db.Cond{"name": name})
+	if err != nil {
+		return FORMATION_INVALID, err
+	}
+	return formation(entry.FormationID), nil
+}
+
+// fits checks whether there is a slot left in the formation for the player
+// once the given players are already placed.
+func (e *formationEntry) fits(players []player, p *player) error {
+	taken := 0
+	for i := range players {
+		if players[i].Position == p.Position {
+			taken++
+		}
+	}
+
+	if taken >= e.Slots[p.Position] {
+		return &slotsError{Formation: e.Name, Position: p.Position}
+	}
+
+	return nil
+}
+
+// slotsError is returned when a formation has no room left for a position.
+type slotsError struct {
+	Formation string
+	Position  position
+}
+
+func (e *slotsError) Error() string {
+	return fmt.Sprintf("formation `%s` has no `%s` slots left", e.Formation, e.Position)
+}
+
+// compare returns how many players per position are still needed to complete
+// the formation and how many do not fit in it.
+func (e *formationEntry) compare(players []player) (missing map[position]int, exceeding map[position]int) {
+	missing = map[position]int{}
+	exceeding = map[position]int{}
+
+	count := map[position]int{}
+	for i := range players {
+		count[players[i].Position]++
+	}
+
+	for pos, n := range e.Slots {
+		if count[pos] < n {
+			missing[pos] = n - count[pos]
+		}
+	}
+
+	for pos, n := range count {
+		if n > e.Slots[pos] {
+			exceeding[pos] = n - e.Slots[pos]
+		}
+	}
+
+	return missing, exceeding
+}
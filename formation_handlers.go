@@ -0,0 +1,333 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func formationID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("formation_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("formation_id", str).Debug("Failed to parse `formation_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `formation_id`")
+		}
+
+		c.Set("formation_id", id)
+
+		return next(c)
+	}
+}
+
+func getFormationID(c echo.Context) (id int64) {
+	id, _ = c.Get("formation_id").(int64)
+	return
+}
+
+const formationsTable = "formations"
+
+var (
+	errFormationNotFound = errors.New("formation not found")
+	errFormationExists   = errors.New("a formation with that name already exists")
+	errFormationLegacy   = errors.New("built-in formations cannot be changed")
+	errFormationInUse    = errors.New("formation is used by lineups")
+)
+
+// lineupFormationsMigration turns the formation of lineups created when
+// formations were a hard-coded enum into a reference to the catalogue. It
+// runs once the legacy formations are seeded, as their IDs are the values of
+// the enum.
+const lineupFormationsMigration = `
+DO $$
+BEGIN
+    IF (SELECT data_type FROM information_schema.columns
+        WHERE table_schema = current_schema() AND table_name = 'lineups' AND column_name = 'formation') = 'smallint' THEN
+        ALTER TABLE lineups
+            ALTER COLUMN formation DROP DEFAULT,
+            ALTER COLUMN formation DROP NOT NULL,
+            ALTER COLUMN formation TYPE INTEGER USING NULLIF(formation, 0),
+            ADD CONSTRAINT lineups_formation_fkey FOREIGN KEY (formation) REFERENCES formations(formation_id);
+    END IF;
+END $$;`
+
+// seedFormations stores the legacy formations unless they already are,
+// migrates the lineups still using the enum and loads the catalogue.
+func (s *server) seedFormations() error {
+	err := s.db.Tx(nil, func(tx sqlbuilder.Tx) error {
+		for i := range legacyFormations {
+			e := &legacyFormations[i]
+			_, err := tx.Exec("INSERT INTO formations (formation_id, name, slots, layout) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+				e.FormationID, e.Name, e.Slots, e.Layout)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(lineupFormationsMigration); err != nil {
+			return err
+		}
+
+		// Keep the IDs of new formations clear of the seeded ones.
+		_, err := tx.Exec("SELECT setval('formations_formation_id_seq', (SELECT MAX(formation_id) FROM formations))")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.loadFormations()
+}
+
+// loadFormations replaces the catalogue in memory with the stored one.
+func (s *server) loadFormations() error {
+	var entries []formationEntry
+
+	err := s.db.Collection(formationsTable).Find().OrderBy("formation_id").All(&entries)
+	if err != nil {
+		return err
+	}
+
+	s.formations.set(entries)
+
+	return nil
+}
+
+// formationsChannel is where server instances announce changes to the
+// stored formations, so every one of them loads its catalogue again.
+const formationsChannel = "formations"
+
+// changedFormations loads the catalogue again after a change made through
+// the API and lets the other instances know about it.
+func (s *server) changedFormations() error {
+	if err := s.loadFormations(); err != nil {
+		return err
+	}
+
+	err := s.redis.Publish(formationsChannel, "changed").Err()
+	if err != nil {
+		log.WithError(err).Error("Failed to announce formation change")
+	}
+
+	return nil
+}
+
+// watchFormations loads the catalogue again every time an instance changes
+// the stored formations.
+func (s *server) watchFormations() {
+	sub := s.redis.Subscribe(formationsChannel)
+	defer sub.Close()
+
+	for range sub.Channel() {
+		if err := s.loadFormations(); err != nil {
+			log.WithError(err).Error("Failed to load formations from the store")
+		}
+	}
+}
+
+// formationError maps the errors of a catalogue change to a response.
+func formationError(c echo.Context, err error) error {
+	switch err {
+	case errFormationNotFound:
+		log.WithField("formation_id", getFormationID(c)).Debug("formation not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errInvalidFormationName, errInvalidFormationSlots, errInvalidFormationLayout:
+		log.WithError(err).Debug("Invalid formation")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errFormationExists, errFormationLegacy, errFormationInUse, errTxConflict:
+		log.WithError(err).Debug("Conflicting formation update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	log.WithError(err).Error("Failed to update formations in the store")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+func (s *server) createFormation(c echo.Context) error {
+	req := new(formationEntry)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure FormationID is not set.
+	if req.FormationID != 0 {
+		log.WithError(fmt.Errorf("formation_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if err := req.check(); err != nil {
+		return formationError(c, err)
+	}
+
+	ret, err := s.db.Collection(formationsTable).Insert(req)
+	if isUniqueViolation(err) {
+		return formationError(c, errFormationExists)
+	}
+	if err != nil {
+		return formationError(c, err)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting a formation")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if err := s.changedFormations(); err != nil {
+		log.WithError(err).Error("Failed to load formations from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &formationEntry{
+		FormationID: id,
+	})
+}
+
+func (s *server) getFormation(c echo.Context) error {
+	found := new(formationEntry)
+
+	err := s.db.Collection(formationsTable).Find("formation_id", getFormationID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		return formationError(c, errFormationNotFound)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve formation from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+func (s *server) listFormations(c echo.Context) error {
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	entries := []formationEntry{}
+
+	err = s.db.Collection(formationsTable).Find().OrderBy("formation_id").
+		Paginate(limit).Page(page).All(&entries)
+	if err != nil {
+		log.WithError(err).Error("Failed to list formations from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &entries)
+}
+
+// updateFormation changes the fields set in the request. Changing the slots
+// of a formation in use does not move the players of its lineups, they are
+// reported by the lineup validation instead.
+func (s *server) updateFormation(c echo.Context) error {
+	req := new(formationEntry)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure FormationID is not set.
+	if req.FormationID != 0 {
+		log.WithError(fmt.Errorf("formation_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		found := new(formationEntry)
+
+		err := tx.SelectFrom(formationsTable).Where("formation_id", getFormationID(c)).
+			Amend(func(query string) string {
+				return query + " FOR UPDATE"
+			}).One(found)
+		if err == db.ErrNoMoreRows {
+			return errFormationNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if found.legacy() {
+			return errFormationLegacy
+		}
+
+		if req.Name != "" {
+			found.Name = req.Name
+		}
+		if req.Slots != nil {
+			found.Slots = req.Slots
+		}
+		if req.Layout != nil {
+			found.Layout = req.Layout
+		}
+
+		if err := found.check(); err != nil {
+			return err
+		}
+
+		err = tx.Collection(formationsTable).Find("formation_id", found.FormationID).Update(found)
+		if isUniqueViolation(err) {
+			return errFormationExists
+		}
+		return err
+	})
+	if err != nil {
+		return formationError(c, err)
+	}
+
+	if err := s.changedFormations(); err != nil {
+		log.WithError(err).Error("Failed to load formations from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteFormation(c echo.Context) error {
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		found := new(formationEntry)
+
+		err := tx.SelectFrom(formationsTable).Where("formation_id", getFormationID(c)).
+			Amend(func(query string) string {
+				return query + " FOR UPDATE"
+			}).One(found)
+		if err == db.ErrNoMoreRows {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if found.legacy() {
+			return errFormationLegacy
+		}
+
+		err = tx.Collection(formationsTable).Find("formation_id", found.FormationID).Delete()
+		if isForeignKeyViolation(err) {
+			return errFormationInUse
+		}
+		return err
+	})
+	if err != nil {
+		return formationError(c, err)
+	}
+
+	if err := s.changedFormations(); err != nil {
+		log.WithError(err).Error("Failed to load formations from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
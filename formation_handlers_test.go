@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormationCatalogueCRUD(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	slots := formationSlots{
+		POSITION_GOALKEEPER:  1,
+		POSITION_DEFENDER:    4,
+		POSITION_MIDDLEFIELD: 3,
+		POSITION_LEFT_WING:   1,
+		POSITION_RIGHT_WING:  1,
+		POSITION_STRIKER:     1,
+	}
+	layout := formationLayout{
+		{POSITION_GOALKEEPER, 50, 5},
+		{POSITION_DEFENDER, 15, 25},
+		{POSITION_DEFENDER, 38, 22},
+		{POSITION_DEFENDER, 62, 22},
+		{POSITION_DEFENDER, 85, 25},
+		{POSITION_MIDDLEFIELD, 38, 40},
+		{POSITION_MIDDLEFIELD, 62, 40},
+		{POSITION_MIDDLEFIELD, 50, 62},
+		{POSITION_LEFT_WING, 15, 65},
+		{POSITION_RIGHT_WING, 85, 65},
+		{POSITION_STRIKER, 50, 85},
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Create 4-2-3-1",
+			Method: "POST",
+			Target: "/formations",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: formationEntry{
+				Name:   "4-2-3-1",
+				Slots:  slots,
+				Layout: layout,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"formation_id":4}`,
+		},
+		{
+			Name:   "Duplicated name",
+			Method: "POST",
+			Target: "/formations",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: formationEntry{
+				Name:   "4-2-3-1",
+				Slots:  slots,
+				Layout: layout,
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"a formation with that name already exists"}`,
+		},
+		{
+			Name:   "Layout does not match the slots",
+			Method: "POST",
+			Target: "/formations",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: formationEntry{
+				Name:   "4-3-3 false nine",
+				Slots:  slots,
+				Layout: layout[:10],
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"` + "`layout`" + ` must place every slot within the pitch"}`,
+		},
+		{
+			Name:               "Get 4-2-3-1",
+			Method:             "GET",
+			Target:             "/formations/4",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"formation_id":4,"name":"4-2-3-1","slots":{"POSITION_DEFENDER":4,"POSITION_GOALKEEPER":1,"POSITION_LEFT_WING":1,"POSITION_MIDDLEFIELD":3,"POSITION_RIGHT_WING":1,"POSITION_STRIKER":1},"layout":[{"position":"POSITION_GOALKEEPER","x":50,"y":5},{"position":"POSITION_DEFENDER","x":15,"y":25},{"position":"POSITION_DEFENDER","x":38,"y":22},{"position":"POSITION_DEFENDER","x":62,"y":22},{"position":"POSITION_DEFENDER","x":85,"y":25},{"position":"POSITION_MIDDLEFIELD","x":38,"y":40},{"position":"POSITION_MIDDLEFIELD","x":62,"y":40},{"position":"POSITION_MIDDLEFIELD","x":50,"y":62},{"position":"POSITION_LEFT_WING","x":15,"y":65},{"position":"POSITION_RIGHT_WING","x":85,"y":65},{"position":"POSITION_STRIKER","x":50,"y":85}]}`,
+		},
+		{
+			Name:               "Get unknown formation",
+			Method:             "GET",
+			Target:             "/formations/9",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"formation not found"}`,
+		},
+		{
+			Name:   "Create lineup with 4-2-3-1",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"formation": "4-2-3-1",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1}`,
+		},
+		{
+			Name:   "Create lineup with a legacy formation",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"formation": "FORMATION_FOUR_FOUR_TWO",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":2}`,
+		},
+		{
+			Name:   "Create lineup with an unknown formation",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"formation": "4-4-1-1",
+			},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:               "Get lineup",
+			Method:             "GET",
+			Target:             "/lineups/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"4-2-3-1","is_local":false}`,
+		},
+		{
+			Name:               "Validate lineup",
+			Method:             "GET",
+			Target:             "/lineups/1/validation",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"4-2-3-1","valid":false,"missing":{"POSITION_DEFENDER":4,"POSITION_GOALKEEPER":1,"POSITION_LEFT_WING":1,"POSITION_MIDDLEFIELD":3,"POSITION_RIGHT_WING":1,"POSITION_STRIKER":1}}`,
+		},
+		{
+			Name:   "Rename a legacy formation",
+			Method: "PUT",
+			Target: "/formations/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: formationEntry{
+				Name: "4-4-2",
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"built-in formations cannot be changed"}`,
+		},
+		{
+			Name:               "Delete a legacy formation",
+			Method:             "DELETE",
+			Target:             "/formations/1",
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"built-in formations cannot be changed"}`,
+		},
+		{
+			Name:   "Rename 4-2-3-1",
+			Method: "PUT",
+			Target: "/formations/4",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: formationEntry{
+				Name: "4-2-3-1 wide",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get renamed lineup formation",
+			Method:             "GET",
+			Target:             "/lineups/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"4-2-3-1 wide","is_local":false}`,
+		},
+		{
+			Name:               "Delete a formation in use",
+			Method:             "DELETE",
+			Target:             "/formations/4",
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"formation is used by lineups"}`,
+		},
+		{
+			Name:               "Delete lineup",
+			Method:             "DELETE",
+			Target:             "/lineups/1",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Delete 4-2-3-1",
+			Method:             "DELETE",
+			Target:             "/formations/4",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get deleted formation",
+			Method:             "GET",
+			Target:             "/formations/4",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"formation not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestFormationFromAnotherInstance(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	// Stored by another instance, so missing from the catalogue in memory.
+	entry := legacyFormations[0]
+	entry.FormationID = 0
+	entry.Name = "4-4-2 diamond"
+
+	_, err := s.db.Collection(formationsTable).Insert(&entry)
+	r.Nil(err)
+
+	body, err := json.Marshal(map[string]interface{}{"formation": "4-4-2 diamond"})
+	r.Nil(err)
+	req := httptest.NewRequest("POST", "/lineups", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal(`{"lineup_id":1}`, strings.TrimRight(rec.Body.String(), "\n"))
+
+	// Another fresh instance resolves the formation of the stored lineup.
+	s.formations = newFormationCatalogue(legacyFormations)
+
+	req = httptest.NewRequest("GET", "/lineups/1", nil)
+	rec = httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+	r.Equal(`{"lineup_id":1,"formation":"4-4-2 diamond","is_local":false}`, strings.TrimRight(rec.Body.String(), "\n"))
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineupFormationText(t *testing.T) {
+	r := require.New(t)
+
+	// Lineups are written with the name of their formation, never its ID.
+	var l lineup
+	r.Nil(json.Unmarshal([]byte(`{"formation":"4-2-3-1"}`), &l))
+	r.Equal(FORMATION_INVALID, l.Formation)
+	r.Equal("4-2-3-1", l.FormationName)
+
+	b, err := json.Marshal(&lineup{Formation: FORMATION_THREE_FOUR_THREE, FormationName: "FORMATION_THREE_FOUR_THREE"})
+	r.Nil(err)
+	r.Equal(`{"formation":"FORMATION_THREE_FOUR_THREE"}`, string(b))
+}
+
+func TestFormationValue(t *testing.T) {
+	r := require.New(t)
+
+	v, err := FORMATION_INVALID.Value()
+	r.Nil(err)
+	r.Nil(v)
+
+	v, err = FORMATION_FOUR_FOUR_TWO.Value()
+	r.Nil(err)
+	r.Equal(int64(1), v)
+
+	var f formation
+	r.Nil(f.Scan(int64(4)))
+	r.Equal(formation(4), f)
+	r.Nil(f.Scan(nil))
+	r.Equal(FORMATION_INVALID, f)
+
+	// Catalogue IDs come from a sequence and are not bounded by the enum.
+	r.Nil(f.Scan(int64(70000)))
+	v, err = f.Value()
+	r.Nil(err)
+	r.Equal(int64(70000), v)
+}
+
+func TestFormationCatalogue(t *testing.T) {
+	r := require.New(t)
+
+	// Cached formations are resolved without touching the store.
+	c := newFormationCatalogue(legacyFormations)
+
+	f, err := c.lookup(nil, "FORMATION_FOUR_FOUR_TWO")
+	r.Nil(err)
+	r.Equal(FORMATION_FOUR_FOUR_TWO, f)
+
+	entries := append([]formationEntry{}, legacyFormations...)
+	entries = append(entries, formationEntry{FormationID: 4, Name: "4-2-3-1"})
+	c.set(entries)
+
+	f, err = c.lookup(nil, "4-2-3-1")
+	r.Nil(err)
+	r.Equal(formation(4), f)
+
+	entry, err := c.get(nil, 4)
+	r.Nil(err)
+	r.Equal("4-2-3-1", entry.Name)
+
+	// A renamed formation is no longer found by its old name.
+	c.add(&formationEntry{FormationID: 4, Name: "4-2-3-1 wide"})
+	f, err = c.lookup(nil, "4-2-3-1 wide")
+	r.Nil(err)
+	r.Equal(formation(4), f)
+	c.mu.RLock()
+	_, ok := c.byName["4-2-3-1"]
+	c.mu.RUnlock()
+	r.False(ok)
+}
+
+func TestFormationEntryCheck(t *testing.T) {
+	slots := formationSlots{
+		POSITION_GOALKEEPER:  1,
+		POSITION_DEFENDER:    5,
+		POSITION_MIDDLEFIELD: 3,
+		POSITION_STRIKER:     2,
+	}
+	layout := formationLayout{
+		{POSITION_GOALKEEPER, 50, 5},
+		{POSITION_DEFENDER, 10, 30},
+		{POSITION_DEFENDER, 30, 22},
+		{POSITION_DEFENDER, 50, 20},
+		{POSITION_DEFENDER, 70, 22},
+		{POSITION_DEFENDER, 90, 30},
+		{POSITION_MIDDLEFIELD, 30, 50},
+		{POSITION_MIDDLEFIELD, 50, 48},
+		{POSITION_MIDDLEFIELD, 70, 50},
+		{POSITION_STRIKER, 40, 80},
+		{POSITION_STRIKER, 60, 80},
+	}
+
+	for _, tc := range []struct {
+		Name     string
+		Entry    formationEntry
+		Expected error
+	}{
+		{
+			Name:  "Valid",
+			Entry: formationEntry{Name: "5-3-2", Slots: slots, Layout: layout},
+		},
+		{
+			Name:     "Missing name",
+			Entry:    formationEntry{Slots: slots, Layout: layout},
+			Expected: errInvalidFormationName,
+		},
+		{
+			Name:     "Numeric name",
+			Entry:    formationEntry{Name: "532", Slots: slots, Layout: layout},
+			Expected: errInvalidFormationName,
+		},
+		{
+			Name:     "Ten players",
+			Entry:    formationEntry{Name: "5-3-1", Slots: formationSlots{POSITION_GOALKEEPER: 1, POSITION_DEFENDER: 5, POSITION_MIDDLEFIELD: 3, POSITION_STRIKER: 1}, Layout: layout},
+			Expected: errInvalidFormationSlots,
+		},
+		{
+			Name:     "Invalid position",
+			Entry:    formationEntry{Name: "5-3-2", Slots: formationSlots{POSITION_INVALID: 1, POSITION_DEFENDER: 5, POSITION_MIDDLEFIELD: 3, POSITION_STRIKER: 2}, Layout: layout},
+			Expected: errInvalidFormationSlots,
+		},
+		{
+			Name:     "Layout misses slots",
+			Entry:    formationEntry{Name: "5-3-2", Slots: slots, Layout: layout[:10]},
+			Expected: errInvalidFormationLayout,
+		},
+		{
+			Name:     "Layout off the pitch",
+			Entry:    formationEntry{Name: "5-3-2", Slots: slots, Layout: append(append(formationLayout{}, layout[:10]...), formationSpot{POSITION_STRIKER, 60, 120})},
+			Expected: errInvalidFormationLayout,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.Equal(t, tc.Expected, tc.Entry.check())
+		})
+	}
+
+	for i := range legacyFormations {
+		require.Nil(t, legacyFormations[i].check(), legacyFormations[i].Name)
+	}
+}
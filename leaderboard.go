@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// leaderboardMetrics are the stats players can be ranked by, as summed over
+// their player_match_stats.
+var leaderboardMetrics = map[string]string{
+	"goals":   "ps.goals",
+	"assists": "ps.assists",
+	"cards":   "ps.yellow_cards + ps.red_cards",
+	"minutes": "ps.minutes",
+}
+
+// leaderboardScope narrows a leaderboard down to the matches of a
+// competition, a season or both. The zero value covers every finished match.
+type leaderboardScope struct {
+	CompetitionID *int64
+	SeasonID      *int64
+}
+
+// key returns the key of the sorted set the leaderboard is kept in.
+func (sc leaderboardScope) key(metric string) string {
+	key := fmt.Sprintf("leaderboards:%s", metric)
+	if sc.CompetitionID != nil {
+		key += fmt.Sprintf(":competition:%d", *sc.CompetitionID)
+	}
+	if sc.SeasonID != nil {
+		key += fmt.Sprintf(":season:%d", *sc.SeasonID)
+	}
+	return key
+}
+
+// leaderboardEntry is a player ranked on a leaderboard. Players level on the
+// metric share their rank and are listed by ID.
+type leaderboardEntry struct {
+	Rank        int64  `json:"rank"`
+	PlayerID    int64  `json:"player_id"`
+	DisplayName string `json:"display_name,omitempty"`
+	Value       int64  `json:"value"`
+}
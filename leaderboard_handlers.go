@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/go-redis/redis"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+)
+
+// leaderboardTTL bounds how long a leaderboard can be served after a change
+// that failed to purge it.
+const leaderboardTTL = 5 * time.Minute
+
+// leaderboardGeneration counts the purges of leaderboards. A leaderboard is
+// only stored if no purge happened while it was being built, as it could
+// have been computed from stats that are now stale.
+const leaderboardGeneration = "leaderboards:generation"
+
+// Leaderboards are kept in sorted sets scored by the negated metric, so
+// ascending order ranks the players with the highest value first and breaks
+// ties by member, the zero padded player ID. The sentinel member marks a
+// leaderboard without any player as built.
+const leaderboardSentinel = "-"
+
+var errLeaderboardPurged = errors.New("leaderboard purged while being built")
+
+func leaderboardMember(playerID int64) string {
+	return fmt.Sprintf("%020d", playerID)
+}
+
+// buildLeaderboard ranks the players on the metric from their match stats
+// and stores the ranking, unless it is already stored. It is built again if
+// leaderboards are purged in the meantime.
+func (s *server) buildLeaderboard(metric string, scope leaderboardScope) error {
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.tryBuildLeaderboard(metric, scope)
+		if err != errLeaderboardPurged && err != redis.TxFailedErr {
+			return err
+		}
+		log.WithField("metric", metric).Debug("Retrying purged leaderboard")
+	}
+	return errTxConflict
+}
+
+func (s *server) tryBuildLeaderboard(metric string, scope leaderboardScope) error {
+	key := scope.key(metric)
+
+	n, err := s.redis.Exists(key).Result()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	generation, err := s.redis.Get(leaderboardGeneration).Int64()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	var rows []struct {
+		PlayerID int64 `db:"player_id"`
+		Value    int64 `db:"value"`
+	}
+
+	// Metrics are never negative, so players whose total is zero are those
+	// without a single match counting towards it.
+	expr := leaderboardMetrics[metric]
+	q := s.db.Select("ps.player_id", db.Raw(fmt.Sprintf("SUM(%s) AS value", expr))).
+		From(fmt.Sprintf("%s AS ps", playerMatchStatsTable)).
+		Join(fmt.Sprintf("%s AS m", matchesTable)).On("m.match_id = ps.match_id").
+		Where(db.Raw(fmt.Sprintf("%s > 0", expr)))
+	if scope.SeasonID != nil {
+		q = q.And("m.season_id", *scope.SeasonID)
+	}
+	if scope.CompetitionID != nil {
+		q = q.And(db.Raw(fmt.Sprintf("m.season_id IN (SELECT season_id FROM %s WHERE competition_id = ?)", seasonsTable), *scope.CompetitionID))
+	}
+
+	err = q.GroupBy("ps.player_id").All(&rows)
+	if err != nil {
+		return err
+	}
+
+	members := []redis.Z{{Score: 0, Member: leaderboardSentinel}}
+	for _, row := range rows {
+		members = append(members, redis.Z{
+			Score:  -float64(row.Value),
+			Member: leaderboardMember(row.PlayerID),
+		})
+	}
+
+	// Watching the generation makes the write fail if a purge happens after
+	// it is checked.
+	return s.redis.Watch(func(tx *redis.Tx) error {
+		current, err := tx.Get(leaderboardGeneration).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if current != generation {
+			return errLeaderboardPurged
+		}
+
+		_, err = tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(key)
+			pipe.ZAdd(key, members...)
+			pipe.Expire(key, leaderboardTTL)
+			return nil
+		})
+		return err
+	}, leaderboardGeneration)
+}
+
+// purgeLeaderboards drops the leaderboards a change to the stats of a match
+// of the season affects, so they are built again on the next read.
+func (s *server) purgeLeaderboards(seasonID *int64) {
+	scopes := []leaderboardScope{{}}
+
+	if seasonID != nil {
+		scopes = append(scopes, leaderboardScope{SeasonID: seasonID})
+
+		found := new(season)
+		err := s.db.Collection(seasonsTable).Find("season_id", *seasonID).One(found)
+		if err != nil && err != db.ErrNoMoreRows {
+			log.WithError(err).WithField("season_id", *seasonID).Error("Failed to retrieve season from the store")
+		}
+		if err == nil {
+			scopes = append(scopes,
+				leaderboardScope{CompetitionID: &found.CompetitionID},
+				leaderboardScope{CompetitionID: &found.CompetitionID, SeasonID: seasonID},
+			)
+		}
+	}
+
+	var keys []string
+	for metric := range leaderboardMetrics {
+		for _, scope := range scopes {
+			keys = append(keys, scope.key(metric))
+		}
+	}
+
+	_, err := s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Incr(leaderboardGeneration)
+		pipe.Del(keys...)
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to purge leaderboards")
+	}
+}
+
+// getLeaderboard returns a page of the players ranked by the metric, scoped
+// by the `competition_id` and `season_id` params.
+func (s *server) getLeaderboard(c echo.Context) error {
+	metric := c.Param("metric")
+	if _, ok := leaderboardMetrics[metric]; !ok {
+		log.WithField("metric", metric).Debug("leaderboard not found")
+		return echo.NewHTTPError(http.StatusNotFound, "leaderboard not found")
+	}
+
+	var scope leaderboardScope
+
+	if str := c.QueryParam("competition_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithError(errInvalidCompetitionValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidCompetitionValue.Error())
+		}
+		scope.CompetitionID = &id
+	}
+
+	if str := c.QueryParam("season_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithError(errInvalidSeasonValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidSeasonValue.Error())
+		}
+		scope.SeasonID = &id
+	}
+
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	err = s.buildLeaderboard(metric, scope)
+	if err == errTxConflict {
+		log.WithError(err).Debug("Conflicting leaderboard purge")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to build leaderboard")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	key := scope.key(metric)
+
+	ranked, err := s.redis.ZRangeByScoreWithScores(key, redis.ZRangeBy{
+		Min:    "-inf",
+		Max:    "(0",
+		Offset: int64((page - 1) * limit),
+		Count:  int64(limit),
+	}).Result()
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve leaderboard")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	entries := []leaderboardEntry{}
+	ids := make([]int64, 0, len(ranked))
+	ranks := map[float64]int64{}
+
+	for _, z := range ranked {
+		member, _ := z.Member.(string)
+		id, err := strconv.ParseInt(member, 10, 64)
+		if err != nil {
+			log.WithError(err).WithField("member", member).Error("Invalid leaderboard member")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		// Players level on the metric share the rank of the first of them.
+		rank, ok := ranks[z.Score]
+		if !ok {
+			ahead, err := s.redis.ZCount(key, "-inf", fmt.Sprintf("(%v", z.Score)).Result()
+			if err != nil {
+				log.WithError(err).Error("Failed to retrieve leaderboard")
+				return c.NoContent(http.StatusInternalServerError)
+			}
+			rank = ahead + 1
+			ranks[z.Score] = rank
+		}
+
+		ids = append(ids, id)
+		entries = append(entries, leaderboardEntry{
+			Rank:     rank,
+			PlayerID: id,
+			Value:    int64(-z.Score),
+		})
+	}
+
+	if len(ids) > 0 {
+		var players []player
+
+		err = s.db.Collection(playersTable).Find("player_id", db.In(ids)).All(&players)
+		if err != nil {
+			log.WithError(err).Error("Failed to list players from the store")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		names := map[int64]string{}
+		for _, p := range players {
+			names[p.PlayerID] = p.DisplayName
+		}
+		for i := range entries {
+			entries[i].DisplayName = names[entries[i].PlayerID]
+		}
+	}
+
+	return c.JSON(http.StatusOK, &entries)
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeaderboards(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, c := range []competition{
+		{CompetitionID: int64(1), Name: "Liga"},
+		{CompetitionID: int64(2), Name: "Copa"},
+	} {
+		_, err := s.db.Collection(competitionsTable).Insert(&c)
+		r.Nil(err)
+	}
+
+	for _, se := range []season{
+		{SeasonID: int64(1), CompetitionID: int64(1), Name: "2020/21", Rounds: 2},
+		{SeasonID: int64(2), CompetitionID: int64(2), Name: "2021", Rounds: 1},
+		{SeasonID: int64(3), CompetitionID: int64(1), Name: "2021/22", Rounds: 2},
+	} {
+		_, err := s.db.Collection(seasonsTable).Insert(&se)
+		r.Nil(err)
+	}
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "A", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "B", Number: 10, Position: POSITION_MIDDLEFIELD},
+		{PlayerID: int64(3), DisplayName: "C", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(4), DisplayName: "D", Number: 4, Position: POSITION_DEFENDER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for i, seasonID := range []int64{1, 2} {
+		home, away := int64(2*i+1), int64(2*i+2)
+
+		for _, l := range []lineup{
+			{LineupID: home, IsLocal: boolPtr(true)},
+			{LineupID: away, IsLocal: boolPtr(false)},
+		} {
+			_, err := s.db.Collection(lineupsTable).Insert(&l)
+			r.Nil(err)
+		}
+
+		for _, lp := range []lineupPlayer{
+			{LineupID: home, PlayerID: int64(1), Role: ROLE_STARTER},
+			{LineupID: home, PlayerID: int64(2), Role: ROLE_STARTER},
+			{LineupID: away, PlayerID: int64(3), Role: ROLE_STARTER},
+			{LineupID: away, PlayerID: int64(4), Role: ROLE_STARTER},
+		} {
+			_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+			r.Nil(err)
+		}
+
+		_, err := s.db.Collection(matchesTable).Insert(&match{
+			MatchID:      int64(i + 1),
+			SeasonID:     int64Ptr(seasonID),
+			HomeLineupID: int64Ptr(home),
+			AwayLineupID: int64Ptr(away),
+			Status:       MATCH_STATUS_LIVE,
+		})
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "A scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(10, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "B assists",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(2),
+				Type:     ACTION_ASSIST,
+				Time:     at(10, 0),
+				GoalID:   int64Ptr(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:   "C scores",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_GOAL,
+				Time:     at(20, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:   "A scores again",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":4}`,
+		},
+		{
+			Name:   "C is booked",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(40, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":5}`,
+		},
+		{
+			Name:               "Unfinished matches do not count",
+			Method:             "GET",
+			Target:             "/leaderboards/goals",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:   "Finish match 1",
+			Method: "PUT",
+			Target: "/matches/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "C scores in the cup",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(3),
+				Type:     ACTION_GOAL,
+				Time:     at(10, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":6}`,
+		},
+		{
+			Name:   "D is booked in the cup",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(4),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(20, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":7}`,
+		},
+		{
+			Name:   "D is booked twice in the cup",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(4),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":8}`,
+		},
+		{
+			Name:   "Finish match 2",
+			Method: "PUT",
+			Target: "/matches/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Unknown leaderboard",
+			Method:             "GET",
+			Target:             "/leaderboards/saves",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"leaderboard not found"}`,
+		},
+		{
+			Name:               "Invalid `season_id` filter",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?season_id=foo",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`season_id`" + ` value"}`,
+		},
+		{
+			Name:               "Top scorers level on goals",
+			Method:             "GET",
+			Target:             "/leaderboards/goals",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":1,"display_name":"A","value":2},{"rank":1,"player_id":3,"display_name":"C","value":2}]`,
+		},
+		{
+			Name:               "Second page of top scorers",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?limit=1&page=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":3,"display_name":"C","value":2}]`,
+		},
+		{
+			Name:               "Page before the first one",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?page=0",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedBody:       `{"message":"Invalid ` + "`page`" + `"}`,
+		},
+		{
+			Name:               "Top scorers of a season",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?season_id=1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":1,"display_name":"A","value":2},{"rank":2,"player_id":3,"display_name":"C","value":1}]`,
+		},
+		{
+			Name:               "Top scorers of a competition",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?competition_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":3,"display_name":"C","value":1}]`,
+		},
+		{
+			Name:               "Top scorers of a season not started",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?season_id=3",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:               "Top assists",
+			Method:             "GET",
+			Target:             "/leaderboards/assists",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":2,"display_name":"B","value":1}]`,
+		},
+		{
+			Name:               "Most booked players",
+			Method:             "GET",
+			Target:             "/leaderboards/cards",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":4,"display_name":"D","value":3},{"rank":2,"player_id":3,"display_name":"C","value":1}]`,
+		},
+		{
+			Name:               "Most minutes played",
+			Method:             "GET",
+			Target:             "/leaderboards/minutes",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":1,"display_name":"A","value":180},{"rank":1,"player_id":2,"display_name":"B","value":180},{"rank":1,"player_id":3,"display_name":"C","value":180},{"rank":4,"player_id":4,"display_name":"D","value":120}]`,
+		},
+		{
+			Name:   "Annul the goal of C in the cup",
+			Method: "DELETE",
+			Target: "/matches/2/actions/6",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Offside",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Top scorers after annulling a goal",
+			Method:             "GET",
+			Target:             "/leaderboards/goals",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"rank":1,"player_id":1,"display_name":"A","value":2},{"rank":2,"player_id":3,"display_name":"C","value":1}]`,
+		},
+		{
+			Name:               "Top scorers of the competition after annulling a goal",
+			Method:             "GET",
+			Target:             "/leaderboards/goals?competition_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestLeaderboardPurge(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	key := leaderboardScope{}.key("goals")
+
+	r.Nil(s.buildLeaderboard("goals", leaderboardScope{}))
+	n, err := s.redis.Exists(key).Result()
+	r.Nil(err)
+	r.Equal(int64(1), n)
+
+	s.purgeLeaderboards(nil)
+
+	n, err = s.redis.Exists(key).Result()
+	r.Nil(err)
+	r.Equal(int64(0), n)
+
+	generation, err := s.redis.Get(leaderboardGeneration).Int64()
+	r.Nil(err)
+	r.Equal(int64(1), generation)
+}
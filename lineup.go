@@ -5,52 +5,102 @@ import (
 	"strconv"
 )
 
-type formation uint16
+// lineup is the side a team puts out for a match. Its formation is stored by
+// ID and written by name, which is resolved through the formation catalogue.
+type lineup struct {
+	LineupID      int64     `json:"lineup_id,omitempty" db:"lineup_id,omitempty"`
+	TeamID        *int64    `json:"team_id,omitempty" db:"team_id,omitempty"`
+	Formation     formation `json:"-" db:"formation,omitempty"`
+	FormationName string    `json:"formation,omitempty" db:"-"`
+	IsLocal       *bool     `json:"is_local,omitempty" db:"is_local,omitempty"`
+}
+
+type lineupRole uint16
 
-func (f formation) String() string {
-	s, ok := formation_name[int(f)]
+func (r lineupRole) String() string {
+	s, ok := lineupRole_name[int(r)]
 	if ok {
 		return s
 	}
-	return strconv.Itoa(int(f))
+	return strconv.Itoa(int(r))
 }
 
-func (f formation) MarshalText() ([]byte, error) {
-	return []byte(f.String()), nil
+func (r lineupRole) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
 }
 
-func (f *formation) UnmarshalText(b []byte) error {
+func (r *lineupRole) UnmarshalText(b []byte) error {
 	s := string(b)
-	if i, ok := formation_value[s]; ok {
-		*f = formation(i)
+	if i, ok := lineupRole_value[s]; ok {
+		*r = lineupRole(i)
 		return nil
 	}
 	return fmt.Errorf("Could not parse %s", b)
 }
 
 const (
-	FORMATION_INVALID formation = iota
-	FORMATION_FOUR_FOUR_TWO
-	FORMATION_FOUR_THREE_THREE
-	FORMATION_THREE_FOUR_THREE
+	ROLE_INVALID lineupRole = iota
+	// ROLE_STARTER players take the field at kickoff and fill the formation.
+	ROLE_STARTER
+	// ROLE_SUBSTITUTE players sit on the bench and may come on.
+	ROLE_SUBSTITUTE
+	// ROLE_UNUSED players are in the squad but left out of the match sheet.
+	ROLE_UNUSED
 )
 
-var formation_name = map[int]string{
-	0: "FORMATION_INVALID",
-	1: "FORMATION_FOUR_FOUR_TWO",
-	2: "FORMATION_FOUR_THREE_THREE",
-	3: "FORMATION_THREE_FOUR_THREE",
+var lineupRole_name = map[int]string{
+	0: "ROLE_INVALID",
+	1: "ROLE_STARTER",
+	2: "ROLE_SUBSTITUTE",
+	3: "ROLE_UNUSED",
 }
 
-var formation_value = map[string]int{
-	"FORMATION_INVALID":          0,
-	"FORMATION_FOUR_FOUR_TWO":    1,
-	"FORMATION_FOUR_THREE_THREE": 2,
-	"FORMATION_THREE_FOUR_THREE": 3,
+var lineupRole_value = map[string]int{
+	"ROLE_INVALID":    0,
+	"ROLE_STARTER":    1,
+	"ROLE_SUBSTITUTE": 2,
+	"ROLE_UNUSED":     3,
 }
 
-type lineup struct {
-	LineupID  int64     `json:"lineup_id,omitempty" db:"lineup_id,omitempty"`
-	Formation formation `json:"formation,omitempty" db:"formation,omitempty"`
-	IsLocal   *bool     `json:"is_local,omitempty" db:"is_local,omitempty"`
+type lineupPlayer struct {
+	LineupID int64      `json:"lineup_id,omitempty" db:"lineup_id,omitempty"`
+	PlayerID int64      `json:"player_id,omitempty" db:"player_id,omitempty"`
+	TeamID   *int64     `json:"team_id,omitempty" db:"team_id,omitempty"`
+	Role     lineupRole `json:"role,omitempty" db:"role,omitempty"`
+}
+
+type lineupValidation struct {
+	LineupID  int64            `json:"lineup_id"`
+	Formation string           `json:"formation,omitempty"`
+	Valid     bool             `json:"valid"`
+	Missing   map[position]int `json:"missing,omitempty"`
+	Exceeding map[position]int `json:"exceeding,omitempty"`
+}
+
+// lineupRoster is the full list of players of a lineup, replaced at once.
+type lineupRoster struct {
+	Players []lineupPlayer `json:"players"`
+}
+
+// rosterError describes why a player of a roster was rejected.
+type rosterError struct {
+	Index    int    `json:"index"`
+	PlayerID int64  `json:"player_id,omitempty"`
+	Message  string `json:"message"`
+}
+
+type rosterErrors []rosterError
+
+func (e rosterErrors) Error() string {
+	return "invalid lineup players"
+}
+
+// lineupWarning flags a player accepted in a lineup who should not be.
+type lineupWarning struct {
+	PlayerID int64  `json:"player_id"`
+	Message  string `json:"message"`
+}
+
+type lineupWarnings struct {
+	Warnings []lineupWarning `json:"warnings"`
 }
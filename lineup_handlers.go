@@ -9,6 +9,7 @@ import (
 	"github.com/apex/log"
 	"github.com/labstack/echo/v4"
 	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
 )
 
 func lineupID(next echo.HandlerFunc) echo.HandlerFunc {
@@ -37,7 +38,14 @@ func getLineupID(c echo.Context) (id int64) {
 
 const lineupsTable = "lineups"
 
-var errLineupNotFound = errors.New("lineup not found")
+var (
+	errLineupNotFound        = errors.New("lineup not found")
+	errLineupSideConflict    = errors.New("lineup is attached to a match on the other side")
+	errLineupFull            = errors.New("lineup has reached maximum players")
+	errPlayerAlreadyInLineup = errors.New("player is already in the lineup")
+	errBenchFull             = errors.New("lineup bench has reached maximum players")
+	errInvalidRole           = errors.New("invalid `role` value")
+)
 
 func (s *server) createLineup(c echo.Context) error {
 	req := new(lineup)
@@ -52,7 +60,15 @@ func (s *server) createLineup(c echo.Context) error {
 		return c.NoContent(http.StatusUnprocessableEntity)
 	}
 
+	if err := s.bindFormation(req); err != nil {
+		return lineupFormationError(c, err)
+	}
+
 	ret, err := s.db.Collection(lineupsTable).Insert(req)
+	if isForeignKeyViolation(err) {
+		log.WithError(err).Debug("team not found")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errTeamNotFound.Error())
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to insert lineup in the store")
 		return c.NoContent(http.StatusInternalServerError)
@@ -82,12 +98,14 @@ func (s *server) getLineup(c echo.Context) error {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	var players []player
+	if _, err := s.lineupFormation(s.db, found); err != nil {
+		log.WithError(err).Error("Failed to retrieve lineup formation from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var roster map[lineupRole][]player
 	if c.QueryParam("with-players") == "true" {
-		err := s.db.Select("p.*").From(fmt.Sprintf("%s AS p", playersTable)).
-			Join(fmt.Sprintf("%s AS l", lineupPlayersTable)).
-			On("p.player_id = l.player_id").And("lineup_id", getLineupID(c)).
-			All(&players)
+		roster, err = lineupPlayers(s.db, getLineupID(c))
 		if err != nil {
 			log.WithError(err).Error("Failed to retrieve lineup with players from the store")
 			return c.NoContent(http.StatusInternalServerError)
@@ -96,10 +114,14 @@ func (s *server) getLineup(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, struct {
 		*lineup
-		Players []player `json:"players,omitempty"`
+		Players     []player `json:"players,omitempty"`
+		Substitutes []player `json:"substitutes,omitempty"`
+		Unused      []player `json:"unused,omitempty"`
 	}{
-		lineup:  found,
-		Players: players,
+		lineup:      found,
+		Players:     roster[ROLE_STARTER],
+		Substitutes: roster[ROLE_SUBSTITUTE],
+		Unused:      roster[ROLE_UNUSED],
 	})
 }
 
@@ -115,8 +137,55 @@ func (s *server) updateLineup(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
-	err := s.db.Collection(lineupsTable).Find("lineup_id", getLineupID(c)).Update(req)
-	if err != nil {
+	if err := s.bindFormation(req); err != nil {
+		return lineupFormationError(c, err)
+	}
+
+	// A lineup attached to a match cannot switch sides.
+	if req.IsLocal != nil {
+		side := "home_lineup_id"
+		if *req.IsLocal {
+			side = "away_lineup_id"
+		}
+
+		count, err := s.db.Collection(matchesTable).Find(side, getLineupID(c)).Count()
+		if err != nil {
+			log.WithError(err).Error("Failed to count matches of lineup")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		if count > 0 {
+			log.WithField("lineup_id", getLineupID(c)).Debug("Lineup is attached to a match on the other side")
+			return echo.NewHTTPError(http.StatusConflict, errLineupSideConflict.Error())
+		}
+	}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		// The players already in the lineup must belong to its new team.
+		if req.TeamID != nil {
+			if err := checkLineupTeam(tx, getLineupID(c), *req.TeamID); err != nil {
+				return err
+			}
+		}
+
+		return tx.Collection(lineupsTable).Find("lineup_id", getLineupID(c)).Update(req)
+	})
+	if isForeignKeyViolation(err) {
+		log.WithError(err).Debug("team not found")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errTeamNotFound.Error())
+	}
+	switch err {
+	case nil:
+	case errLineupNotFound:
+		log.WithField("lineup_id", getLineupID(c)).Debug("lineup not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errPlayerNotInTeam:
+		log.WithError(err).Debug("Lineup players belong to another team")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errTxConflict:
+		log.WithError(err).Debug("Conflicting lineup update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
 		log.WithError(err).Error("Failed to update lineup from the store")
 		return c.NoContent(http.StatusInternalServerError)
 	}
@@ -124,6 +193,51 @@ func (s *server) updateLineup(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+// checkLineupTeam ensures every player of the lineup was registered to the
+// team on the day of the match the lineup is picked for, or today when there
+// is none. The lineup is locked so its roster cannot change meanwhile.
+func checkLineupTeam(tx sqlbuilder.Tx, lineupID, teamID int64) error {
+	if _, err := lockLineup(tx, lineupID); err != nil {
+		return err
+	}
+
+	on := today()
+
+	m := new(match)
+	err := tx.SelectFrom(matchesTable).
+		Where("home_lineup_id = ? OR away_lineup_id = ?", lineupID, lineupID).One(m)
+	if err != nil && err != db.ErrNoMoreRows {
+		return err
+	}
+	if err == nil && m.Kickoff != nil {
+		on = dateOf(*m.Kickoff)
+	}
+
+	var players []player
+
+	err = tx.SelectFrom(playersTable).
+		Where(db.Raw("player_id IN (SELECT player_id FROM lineup_players WHERE lineup_id = ?)", lineupID)).
+		OrderBy("player_id").All(&players)
+	if err != nil {
+		return err
+	}
+
+	for i := range players {
+		transfers, err := playerTransfers(tx, players[i].PlayerID)
+		if err != nil {
+			return err
+		}
+
+		registered := teamOnDate(&players[i], transfers, on)
+		if registered == nil || *registered != teamID {
+			log.WithField("player_id", players[i].PlayerID).Debug("Player is not registered to the team")
+			return errPlayerNotInTeam
+		}
+	}
+
+	return nil
+}
+
 func (s *server) deleteLineup(c echo.Context) error {
 	err := s.db.Collection(lineupsTable).Find("lineup_id", getLineupID(c)).Delete()
 	if err != nil {
@@ -134,10 +248,204 @@ func (s *server) deleteLineup(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+// bindFormation resolves the formation of a lineup request from its name.
+func (s *server) bindFormation(l *lineup) error {
+	if l.FormationName == "" {
+		return nil
+	}
+
+	f, err := s.formations.lookup(s.db, l.FormationName)
+	if err != nil {
+		return err
+	}
+
+	l.Formation = f
+
+	return nil
+}
+
+// lineupFormation returns the formation of the lineup, nil if it has none,
+// and fills in its name.
+func (s *server) lineupFormation(sess sqlbuilder.SQLBuilder, l *lineup) (*formationEntry, error) {
+	if l.Formation == FORMATION_INVALID {
+		return nil, nil
+	}
+
+	entry, err := s.formations.get(sess, l.Formation)
+	if err != nil {
+		return nil, err
+	}
+
+	l.FormationName = entry.Name
+
+	return entry, nil
+}
+
+func lineupFormationError(c echo.Context, err error) error {
+	if err == errFormationNotFound {
+		log.WithError(err).Debug("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	log.WithError(err).Error("Failed to retrieve formation from the store")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
 const lineupPlayersTable = "lineup_players"
 
+// lineupPlayers returns the players of the lineup grouped by their role. The
+// team of each player is the one they were registered to when they joined
+// the lineup, so transfers do not rewrite past lineups.
+func lineupPlayers(sess sqlbuilder.SQLBuilder, lineupID int64) (map[lineupRole][]player, error) {
+	var players []struct {
+		player `db:",inline"`
+		Role   lineupRole `db:"role"`
+	}
+
+	err := sess.Select("p.player_id", "l.team_id", "p.display_name", "p.number", "p.position", "l.role").From(fmt.Sprintf("%s AS p", playersTable)).
+		Join(fmt.Sprintf("%s AS l", lineupPlayersTable)).
+		On("p.player_id = l.player_id").And("lineup_id", lineupID).
+		OrderBy("p.player_id").All(&players)
+	if err != nil {
+		return nil, err
+	}
+
+	roster := map[lineupRole][]player{}
+	for _, p := range players {
+		roster[p.Role] = append(roster[p.Role], p.player)
+	}
+
+	return roster, nil
+}
+
+// admit checks whether the player can join the lineup with the given role
+// next to the players already in it. Lineups of a team only take players
+// registered to it and only starters are bound by the formation, if any.
+func (s *server) admit(l *lineup, f *formationEntry, roster map[lineupRole][]player, p *player, role lineupRole) error {
+	if l.TeamID != nil && (p.TeamID == nil || *p.TeamID != *l.TeamID) {
+		return errPlayerNotInTeam
+	}
+
+	switch role {
+	case ROLE_STARTER:
+		// Check if lineup has already 11 players.
+		if len(roster[ROLE_STARTER]) >= 11 {
+			return errLineupFull
+		}
+
+		// Check the player's position still has a slot left in the formation.
+		if f != nil {
+			return f.fits(roster[ROLE_STARTER], p)
+		}
+	case ROLE_SUBSTITUTE:
+		if len(roster[ROLE_SUBSTITUTE]) >= s.config.benchSize {
+			return errBenchFull
+		}
+	case ROLE_UNUSED:
+	default:
+		return errInvalidRole
+	}
+
+	return nil
+}
+
+// lineupRestrictions are what keeps players out of a lineup besides its own
+// limits: suspensions in the competition of the match and absences on the
+// day it is played.
+type lineupRestrictions struct {
+	suspended   map[int64]*suspension
+	unavailable map[int64]*availability
+	// block rejects unavailable players instead of warning about them.
+	block bool
+}
+
+// restrictions looks up the restrictions of the given players for the match
+// the lineup is picked for, if any.
+func (s *server) restrictions(tx sqlbuilder.Tx, lineupID int64, playerIDs []int64) (*lineupRestrictions, error) {
+	m := new(match)
+
+	err := tx.SelectFrom(matchesTable).
+		Where("home_lineup_id = ? OR away_lineup_id = ?", lineupID, lineupID).One(m)
+	if err == db.ErrNoMoreRows {
+		m = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s.matchRestrictions(tx, m, playerIDs)
+}
+
+// matchRestrictions looks up the restrictions of the given players for the
+// match, or for today when there is none. Finished matches are not
+// restricted so their lineups can still be corrected.
+func (s *server) matchRestrictions(sess sqlbuilder.SQLBuilder, m *match, playerIDs []int64) (*lineupRestrictions, error) {
+	r := &lineupRestrictions{block: s.config.blockUnavailable}
+
+	var err error
+
+	on := today()
+	if m != nil {
+		if m.Status == MATCH_STATUS_FINISHED {
+			return r, nil
+		}
+
+		if m.Kickoff != nil {
+			on = dateOf(*m.Kickoff)
+		}
+
+		r.suspended, err = s.matchSuspensions(sess, m)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.unavailable, err = unavailablePlayers(sess, playerIDs, on)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// check returns why the player cannot join the lineup, or a warning when
+// they can but are unavailable.
+func (r *lineupRestrictions) check(playerID int64) (*lineupWarning, error) {
+	if sp := r.suspended[playerID]; sp != nil {
+		return nil, &suspendedError{Suspension: sp}
+	}
+
+	if a := r.unavailable[playerID]; a != nil {
+		err := &unavailableError{Availability: a}
+		if r.block {
+			return nil, err
+		}
+		return &lineupWarning{PlayerID: playerID, Message: err.Error()}, nil
+	}
+
+	return nil, nil
+}
+
+// lockLineup retrieves the lineup locking its row until the transaction ends,
+// so concurrent roster changes on the same lineup are serialized.
+func lockLineup(tx sqlbuilder.Tx, lineupID int64) (*lineup, error) {
+	found := new(lineup)
+
+	err := tx.SelectFrom(lineupsTable).Where("lineup_id", lineupID).
+		Amend(func(query string) string {
+			return query + " FOR UPDATE"
+		}).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errLineupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
 func (s *server) addPlayerToLineup(c echo.Context) error {
-	req := new(player)
+	req := new(lineupPlayer)
 	if err := c.Bind(req); err != nil {
 		return err
 	}
@@ -150,46 +458,341 @@ func (s *server) addPlayerToLineup(c echo.Context) error {
 		return c.NoContent(http.StatusUnprocessableEntity)
 	}
 
-	// TODO: enclose this part in a transaction.
-	count, err := s.db.Collection(lineupPlayersTable).Find("lineup_id", getLineupID(c)).Count()
+	if req.Role == ROLE_INVALID {
+		req.Role = ROLE_STARTER
+	}
+
+	var (
+		refreshed []match
+		warnings  []lineupWarning
+	)
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		warnings = nil
+
+		found, err := lockLineup(tx, getLineupID(c))
+		if err != nil {
+			return err
+		}
+
+		f, err := s.lineupFormation(tx, found)
+		if err != nil {
+			return err
+		}
+
+		roster, err := lineupPlayers(tx, found.LineupID)
+		if err != nil {
+			return err
+		}
+
+		p := new(player)
+		err = tx.Collection(playersTable).Find("player_id", req.PlayerID).One(p)
+		if err == db.ErrNoMoreRows {
+			return errPlayerNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := s.admit(found, f, roster, p, req.Role); err != nil {
+			return err
+		}
+
+		restrictions, err := s.restrictions(tx, found.LineupID, []int64{p.PlayerID})
+		if err != nil {
+			return err
+		}
+
+		warning, err := restrictions.check(p.PlayerID)
+		if err != nil {
+			return err
+		}
+		if warning != nil {
+			warnings = append(warnings, *warning)
+		}
+
+		_, err = tx.Collection(lineupPlayersTable).Insert(&lineupPlayer{
+			LineupID: found.LineupID,
+			PlayerID: p.PlayerID,
+			TeamID:   p.TeamID,
+			Role:     req.Role,
+		})
+		if isUniqueViolation(err) {
+			return errPlayerAlreadyInLineup
+		}
+		if err != nil {
+			return err
+		}
+
+		refreshed, err = s.refreshLineupMatchStats(tx, found.LineupID)
+		return err
+	})
 	if err != nil {
-		log.WithError(err).Error("Failed to count players from lineup")
-		return c.NoContent(http.StatusInternalServerError)
+		return lineupPlayersError(c, err)
 	}
 
-	// Check if lineup has already 11 players.
-	if count == uint64(11) {
-		log.WithField("lineup_id", getLineupID(c)).Debug("Lineup has reached maximum players")
-		return echo.NewHTTPError(http.StatusForbidden, "lineup has reached maximum players")
+	for i := range refreshed {
+		s.purgeLeaderboards(refreshed[i].SeasonID)
 	}
 
-	_, err = s.db.Collection(lineupPlayersTable).Insert(&struct {
-		PlayerID int64 `db:"player_id"`
-		LineupID int64 `db:"lineup_id"`
-	}{
-		LineupID: getLineupID(c),
-		PlayerID: req.PlayerID,
+	if len(warnings) > 0 {
+		return c.JSON(http.StatusOK, &lineupWarnings{Warnings: warnings})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) replaceLineupPlayers(c echo.Context) error {
+	req := new(lineupRoster)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if getLineupID(c) == 0 {
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	var (
+		refreshed []match
+		warnings  []lineupWarning
+	)
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		warnings = nil
+
+		found, err := lockLineup(tx, getLineupID(c))
+		if err != nil {
+			return err
+		}
+
+		f, err := s.lineupFormation(tx, found)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int64, 0, len(req.Players))
+		for _, item := range req.Players {
+			ids = append(ids, item.PlayerID)
+		}
+
+		var stored []player
+		if len(ids) > 0 {
+			err = tx.Collection(playersTable).Find("player_id", db.In(ids)).All(&stored)
+			if err != nil {
+				return err
+			}
+		}
+
+		byID := map[int64]*player{}
+		for i := range stored {
+			byID[stored[i].PlayerID] = &stored[i]
+		}
+
+		restrictions, err := s.restrictions(tx, found.LineupID, ids)
+		if err != nil {
+			return err
+		}
+
+		var (
+			errs     rosterErrors
+			accepted []lineupPlayer
+			roster   = map[lineupRole][]player{}
+			seen     = map[int64]bool{}
+		)
+
+		for i, item := range req.Players {
+			var err error
+
+			if item.Role == ROLE_INVALID {
+				item.Role = ROLE_STARTER
+			}
+
+			p := byID[item.PlayerID]
+			switch {
+			case item.PlayerID == 0:
+				err = errors.New("invalid `player_id`")
+			case item.LineupID != 0 && item.LineupID != found.LineupID:
+				err = errors.New("`lineup_id` does not match the lineup")
+			case seen[item.PlayerID]:
+				err = errors.New("player is duplicated")
+			case p == nil:
+				err = errPlayerNotFound
+			default:
+				err = s.admit(found, f, roster, p, item.Role)
+			}
+
+			if err == nil {
+				var warning *lineupWarning
+				warning, err = restrictions.check(item.PlayerID)
+				if warning != nil {
+					warnings = append(warnings, *warning)
+				}
+			}
+
+			seen[item.PlayerID] = true
+
+			if err != nil {
+				errs = append(errs, rosterError{
+					Index:    i,
+					PlayerID: item.PlayerID,
+					Message:  err.Error(),
+				})
+				continue
+			}
+
+			roster[item.Role] = append(roster[item.Role], *p)
+			accepted = append(accepted, lineupPlayer{
+				LineupID: found.LineupID,
+				PlayerID: item.PlayerID,
+				TeamID:   p.TeamID,
+				Role:     item.Role,
+			})
+		}
+
+		if len(errs) > 0 {
+			return errs
+		}
+
+		err = tx.Collection(lineupPlayersTable).Find("lineup_id", found.LineupID).Delete()
+		if err != nil {
+			return err
+		}
+
+		for i := range accepted {
+			_, err = tx.Collection(lineupPlayersTable).Insert(&accepted[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		refreshed, err = s.refreshLineupMatchStats(tx, found.LineupID)
+		return err
 	})
 	if err != nil {
-		log.WithError(err).Error("Failed to insert player in the lineup")
-		return c.NoContent(http.StatusInternalServerError)
+		return lineupPlayersError(c, err)
+	}
+
+	for i := range refreshed {
+		s.purgeLeaderboards(refreshed[i].SeasonID)
+	}
+
+	if len(warnings) > 0 {
+		return c.JSON(http.StatusOK, &lineupWarnings{Warnings: warnings})
 	}
 
 	return c.NoContent(http.StatusOK)
 }
 
+// lineupPlayersError maps the errors of a roster change to a response.
+func lineupPlayersError(c echo.Context, err error) error {
+	switch err {
+	case errLineupNotFound:
+		log.WithField("lineup_id", getLineupID(c)).Debug("lineup not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errLineupFull, errBenchFull:
+		log.WithField("lineup_id", getLineupID(c)).Debug("Lineup has reached maximum players")
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errPlayerNotFound, errInvalidRole, errPlayerNotInTeam:
+		log.WithError(err).Debug("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errPlayerAlreadyInLineup, errTxConflict:
+		log.WithError(err).Debug("Conflicting lineup update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	if errs, ok := err.(rosterErrors); ok {
+		log.WithError(err).Debug("Invalid lineup players")
+		return c.JSON(http.StatusUnprocessableEntity, struct {
+			Message string        `json:"message"`
+			Errors  []rosterError `json:"errors"`
+		}{
+			Message: errs.Error(),
+			Errors:  errs,
+		})
+	}
+
+	if _, ok := err.(*slotsError); ok {
+		log.WithError(err).Debug("Player does not fit in the lineup formation")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	switch err.(type) {
+	case *suspendedError, *unavailableError:
+		log.WithError(err).Debug("Player cannot be picked")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	log.WithError(err).Error("Failed to update lineup players in the store")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+func (s *server) validateLineup(c echo.Context) error {
+	found := new(lineup)
+
+	err := s.db.Collection(lineupsTable).Find("lineup_id", getLineupID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("lineup_id", getLineupID(c)).Debug("lineup not found")
+		return echo.NewHTTPError(http.StatusNotFound, errLineupNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve lineup from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	roster, err := lineupPlayers(s.db, getLineupID(c))
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve lineup players from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	f, err := s.lineupFormation(s.db, found)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve lineup formation from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// Lineups without a formation cannot be complete.
+	var missing, exceeding map[position]int
+	if f != nil {
+		missing, exceeding = f.compare(roster[ROLE_STARTER])
+	}
+
+	return c.JSON(http.StatusOK, &lineupValidation{
+		LineupID:  found.LineupID,
+		Formation: found.FormationName,
+		Valid:     f != nil && len(missing) == 0 && len(exceeding) == 0,
+		Missing:   missing,
+		Exceeding: exceeding,
+	})
+}
+
 func (s *server) deletePlayerFromLineup(c echo.Context) error {
 	req := new(player)
 	if err := c.Bind(req); err != nil {
 		return err
 	}
 
-	err := s.db.Collection(lineupPlayersTable).Find("lineup_id", getLineupID(c)).
-		And("player_id", req.PlayerID).Delete()
+	var refreshed []match
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		err := tx.Collection(lineupPlayersTable).Find("lineup_id", getLineupID(c)).
+			And("player_id", req.PlayerID).Delete()
+		if err != nil {
+			return err
+		}
+
+		refreshed, err = s.refreshLineupMatchStats(tx, getLineupID(c))
+		return err
+	})
 	if err != nil {
 		log.WithError(err).Error("Failed to delete player from lineup")
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
+	for i := range refreshed {
+		s.purgeLeaderboards(refreshed[i].SeasonID)
+	}
+
 	return c.NoContent(http.StatusOK)
 }
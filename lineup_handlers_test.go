@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/bxcodec/faker"
@@ -34,9 +35,9 @@ func TestLineupCRUD(t *testing.T) {
 				req.Header.Set("Content-Type", "application/json")
 			},
 			Body: lineup{
-				LineupID:  int64(1),
-				Formation: FORMATION_FOUR_FOUR_TWO,
-				IsLocal:   boolPtr(true),
+				LineupID:      int64(1),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
 			},
 			ExpectedStatusCode: http.StatusUnprocessableEntity,
 		},
@@ -48,8 +49,8 @@ func TestLineupCRUD(t *testing.T) {
 				req.Header.Set("Content-Type", "application/json")
 			},
 			Body: lineup{
-				Formation: FORMATION_FOUR_FOUR_TWO,
-				IsLocal:   boolPtr(true),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
 			},
 			ExpectedStatusCode: http.StatusOK,
 			ExpectedBody:       `{"lineup_id":1}`,
@@ -62,8 +63,8 @@ func TestLineupCRUD(t *testing.T) {
 				req.Header.Set("Content-Type", "application/json")
 			},
 			Body: lineup{
-				Formation: FORMATION_FOUR_THREE_THREE,
-				IsLocal:   boolPtr(false),
+				FormationName: "FORMATION_FOUR_THREE_THREE",
+				IsLocal:       boolPtr(false),
 			},
 			ExpectedStatusCode: http.StatusOK,
 			ExpectedBody:       `{"lineup_id":2}`,
@@ -157,7 +158,7 @@ func TestLineup11Players(t *testing.T) {
 		PlayerID:    int64(2),
 		DisplayName: "Bar",
 		Number:      4,
-		Position:    POSITION_RIGHT_WING,
+		Position:    POSITION_STRIKER,
 	}
 	_, err = s.db.Collection(playersTable).Insert(&player2)
 	r.Nil(err)
@@ -216,7 +217,7 @@ func TestLineup11Players(t *testing.T) {
 			Method:             "GET",
 			Target:             "/lineups/1?with-players=true",
 			ExpectedStatusCode: http.StatusOK,
-			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":false,"players":[{"player_id":1,"display_name":"Foo","number":1,"position":"POSITION_RIGHT_WING"},{"player_id":2,"display_name":"Bar","number":4,"position":"POSITION_RIGHT_WING"}]}`,
+			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":false,"players":[{"player_id":1,"display_name":"Foo","number":1,"position":"POSITION_RIGHT_WING"},{"player_id":2,"display_name":"Bar","number":4,"position":"POSITION_STRIKER"}]}`,
 		},
 		{
 			Name:   "Delete player 2 from lineup",
@@ -354,3 +355,452 @@ func TestLineupPlayersLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestLineupFormation(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 13, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 9, Position: POSITION_STRIKER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(lineupsTable).Insert(&lineup{
+		LineupID:  int64(1),
+		Formation: FORMATION_FOUR_FOUR_TWO,
+		IsLocal:   boolPtr(true),
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Add goalkeeper",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Add second goalkeeper",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				PlayerID: int64(2),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       "{\"message\":\"formation `FORMATION_FOUR_FOUR_TWO` has no `POSITION_GOALKEEPER` slots left\"}",
+		},
+		{
+			Name:   "Add unknown player",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				PlayerID: int64(4),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player not found"}`,
+		},
+		{
+			Name:   "Add striker",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				PlayerID: int64(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Validate lineup",
+			Method:             "GET",
+			Target:             "/lineups/1/validation",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","valid":false,"missing":{"POSITION_DEFENDER":4,"POSITION_LEFT_WING":1,"POSITION_MIDDLEFIELD":2,"POSITION_RIGHT_WING":1,"POSITION_STRIKER":1}}`,
+		},
+		{
+			Name:               "Validate unknown lineup",
+			Method:             "GET",
+			Target:             "/lineups/2/validation",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"lineup not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestLineupConcurrentAdds(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(lineupsTable).Insert(&lineup{
+		LineupID: int64(1),
+		IsLocal:  boolPtr(true),
+	})
+	r.Nil(err)
+
+	var players [20]player
+
+	for i := range players {
+		r.Nil(faker.FakeData(&players[i]))
+		players[i].PlayerID = int64(i + 1)
+
+		_, err := s.db.Collection(playersTable).Insert(&players[i])
+		r.Nil(err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		codes = map[int]int{}
+	)
+
+	for i := range players {
+		wg.Add(1)
+		go func(p player) {
+			defer wg.Done()
+
+			body, _ := json.Marshal(&player{PlayerID: p.PlayerID})
+			req := httptest.NewRequest("POST", "/lineups/1/players", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			rec := httptest.NewRecorder()
+			s.web.ServeHTTP(rec, req)
+
+			mu.Lock()
+			codes[rec.Code]++
+			mu.Unlock()
+		}(players[i])
+	}
+
+	wg.Wait()
+
+	r.Equal(11, codes[http.StatusOK])
+	r.Equal(len(players)-11, codes[http.StatusForbidden]+codes[http.StatusConflict])
+
+	count, err := s.db.Collection(lineupPlayersTable).Find("lineup_id", 1).Count()
+	r.Nil(err)
+	r.Equal(uint64(11), count)
+}
+
+func TestLineupRosterReplace(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 13, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(4), DisplayName: "Qux", Number: 10, Position: POSITION_STRIKER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	_, err := s.db.Collection(lineupsTable).Insert(&lineup{
+		LineupID:  int64(1),
+		Formation: FORMATION_FOUR_FOUR_TWO,
+		IsLocal:   boolPtr(true),
+	})
+	r.Nil(err)
+
+	_, err = s.db.Collection(lineupPlayersTable).Insert(&lineupPlayer{
+		LineupID: int64(1),
+		PlayerID: int64(3),
+	})
+	r.Nil(err)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Replace roster with invalid players",
+			Method: "PUT",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupRoster{
+				Players: []lineupPlayer{
+					{PlayerID: int64(1)},
+					{PlayerID: int64(2)},
+					{PlayerID: int64(2)},
+					{PlayerID: int64(9)},
+				},
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       "{\"message\":\"invalid lineup players\",\"errors\":[{\"index\":1,\"player_id\":2,\"message\":\"formation `FORMATION_FOUR_FOUR_TWO` has no `POSITION_GOALKEEPER` slots left\"},{\"index\":2,\"player_id\":2,\"message\":\"player is duplicated\"},{\"index\":3,\"player_id\":9,\"message\":\"player not found\"}]}",
+		},
+		{
+			Name:               "Roster is unchanged",
+			Method:             "GET",
+			Target:             "/lineups/1?with-players=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":true,"players":[{"player_id":3,"display_name":"Baz","number":9,"position":"POSITION_STRIKER"}]}`,
+		},
+		{
+			Name:   "Replace roster",
+			Method: "PUT",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupRoster{
+				Players: []lineupPlayer{
+					{PlayerID: int64(1)},
+					{PlayerID: int64(4)},
+				},
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Roster is replaced",
+			Method:             "GET",
+			Target:             "/lineups/1?with-players=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":true,"players":[{"player_id":1,"display_name":"Foo","number":1,"position":"POSITION_GOALKEEPER"},{"player_id":4,"display_name":"Qux","number":10,"position":"POSITION_STRIKER"}]}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
+
+func TestLineupRoles(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 13, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 25, Position: POSITION_GOALKEEPER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	// Fill the bench of the second lineup.
+	for i := 0; i < s.config.benchSize; i++ {
+		p := new(player)
+		r.Nil(faker.FakeData(p))
+		p.PlayerID = int64(10 + i)
+
+		_, err := s.db.Collection(playersTable).Insert(p)
+		r.Nil(err)
+
+		_, err = s.db.Collection(lineupPlayersTable).Insert(&lineupPlayer{
+			LineupID: int64(2),
+			PlayerID: p.PlayerID,
+			Role:     ROLE_SUBSTITUTE,
+		})
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Add starting goalkeeper",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Add substitute goalkeeper",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(2),
+				Role:     ROLE_SUBSTITUTE,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Add unused goalkeeper",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(3),
+				Role:     ROLE_UNUSED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get lineup with players grouped by role",
+			Method:             "GET",
+			Target:             "/lineups/1?with-players=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":true,"players":[{"player_id":1,"display_name":"Foo","number":1,"position":"POSITION_GOALKEEPER"}],"substitutes":[{"player_id":2,"display_name":"Bar","number":13,"position":"POSITION_GOALKEEPER"}],"unused":[{"player_id":3,"display_name":"Baz","number":25,"position":"POSITION_GOALKEEPER"}]}`,
+		},
+		{
+			Name:   "Add substitute to a full bench",
+			Method: "POST",
+			Target: "/lineups/2/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+				Role:     ROLE_SUBSTITUTE,
+			},
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedBody:       `{"message":"lineup bench has reached maximum players"}`,
+		},
+		{
+			Name:   "Add starter next to a full bench",
+			Method: "POST",
+			Target: "/lineups/2/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
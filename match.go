@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+type matchStatus uint16
+
+func (m matchStatus) String() string {
+	s, ok := matchStatus_name[int(m)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(m))
+}
+
+func (m matchStatus) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *matchStatus) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := matchStatus_value[s]; ok {
+		*m = matchStatus(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
+const (
+	MATCH_STATUS_INVALID matchStatus = iota
+	MATCH_STATUS_SCHEDULED
+	MATCH_STATUS_LIVE
+	MATCH_STATUS_FINISHED
+	MATCH_STATUS_POSTPONED
+	MATCH_STATUS_CANCELLED
+)
+
+var matchStatus_name = map[int]string{
+	0: "MATCH_STATUS_INVALID",
+	1: "MATCH_STATUS_SCHEDULED",
+	2: "MATCH_STATUS_LIVE",
+	3: "MATCH_STATUS_FINISHED",
+	4: "MATCH_STATUS_POSTPONED",
+	5: "MATCH_STATUS_CANCELLED",
+}
+
+var matchStatus_value = map[string]int{
+	"MATCH_STATUS_INVALID":   0,
+	"MATCH_STATUS_SCHEDULED": 1,
+	"MATCH_STATUS_LIVE":      2,
+	"MATCH_STATUS_FINISHED":  3,
+	"MATCH_STATUS_POSTPONED": 4,
+	"MATCH_STATUS_CANCELLED": 5,
+}
+
+// match pairs a local (home) lineup with its visiting (away) opponent. Lineups
+// are optional so a match can be scheduled before either side is picked.
+// League matches belong to a season and are played on a matchday between
+// two teams, whose lineups must be picked by them.
+type match struct {
+	MatchID      int64       `json:"match_id,omitempty" db:"match_id,omitempty"`
+	SeasonID     *int64      `json:"season_id,omitempty" db:"season_id,omitempty"`
+	Matchday     int         `json:"matchday,omitempty" db:"matchday,omitempty"`
+	HomeTeamID   *int64      `json:"home_team_id,omitempty" db:"home_team_id,omitempty"`
+	AwayTeamID   *int64      `json:"away_team_id,omitempty" db:"away_team_id,omitempty"`
+	HomeLineupID *int64      `json:"home_lineup_id,omitempty" db:"home_lineup_id,omitempty"`
+	AwayLineupID *int64      `json:"away_lineup_id,omitempty" db:"away_lineup_id,omitempty"`
+	Kickoff      *time.Time  `json:"kickoff,omitempty" db:"kickoff,omitempty"`
+	Venue        string      `json:"venue,omitempty" db:"venue,omitempty"`
+	Status       matchStatus `json:"status,omitempty" db:"status,omitempty"`
+
+	// Score is derived from the match actions and never stored.
+	Score *score `json:"score,omitempty" db:"-"`
+}
+
+type score struct {
+	Home int `json:"home"`
+	Away int `json:"away"`
+}
+
+// add counts a goal towards the score of the match. Own goals count for the
+// opponent of the lineup the player belongs to. Goals of lineups no longer
+// attached to the match do not count.
+func (sc *score) add(m *match, a *action) {
+	if !m.isHome(a.LineupID) && !m.isAway(a.LineupID) {
+		return
+	}
+
+	var home bool
+	switch a.Type {
+	case ACTION_GOAL:
+		home = m.isHome(a.LineupID)
+	case ACTION_GOAL_OWN:
+		home = m.isAway(a.LineupID)
+	default:
+		return
+	}
+
+	if home {
+		sc.Home++
+	} else {
+		sc.Away++
+	}
+}
+
+// pitch lists the players of each side on the pitch at a given time.
+type pitch struct {
+	Home []player `json:"home,omitempty"`
+	Away []player `json:"away,omitempty"`
+}
+
+type playerMinutes struct {
+	PlayerID int64  `json:"player_id"`
+	LineupID int64  `json:"lineup_id"`
+	Minutes  uint64 `json:"minutes"`
+}
@@ -0,0 +1,624 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func matchID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("match_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("match_id", str).Debug("Failed to parse `match_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `match_id`")
+		}
+
+		c.Set("match_id", id)
+
+		return next(c)
+	}
+}
+
+func getMatchID(c echo.Context) (id int64) {
+	id, _ = c.Get("match_id").(int64)
+	return
+}
+
+const matchesTable = "matches"
+
+var (
+	errMatchNotFound       = errors.New("match not found")
+	errHomeLineupNotFound  = errors.New("home lineup not found")
+	errAwayLineupNotFound  = errors.New("away lineup not found")
+	errHomeLineupNotLocal  = errors.New("home lineup must have `is_local` set to true")
+	errAwayLineupIsLocal   = errors.New("away lineup must have `is_local` set to false")
+	errSameLineups         = errors.New("home and away lineups must be different")
+	errLineupAlreadyPlayed = errors.New("lineup is already attached to another match")
+	errHomeLineupTeam      = errors.New("home lineup is not picked by the home team")
+	errAwayLineupTeam      = errors.New("away lineup is not picked by the away team")
+	errMatchReference      = errors.New("season or team not found")
+)
+
+func (s *server) findMatch(id int64) (*match, error) {
+	found := new(match)
+
+	err := s.db.Collection(matchesTable).Find("match_id", id).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errMatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// lockMatch retrieves the match locking its row until the transaction ends,
+// so concurrent actions on the same match are serialized.
+func lockMatch(tx sqlbuilder.Tx, id int64) (*match, error) {
+	found := new(match)
+
+	err := tx.SelectFrom(matchesTable).Where("match_id", id).
+		Amend(func(query string) string {
+			return query + " FOR UPDATE"
+		}).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errMatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// isHome reports whether the lineup plays as the home side of the match.
+func (m *match) isHome(lineupID int64) bool {
+	return m.HomeLineupID != nil && *m.HomeLineupID == lineupID
+}
+
+// isAway reports whether the lineup plays as the away side of the match.
+func (m *match) isAway(lineupID int64) bool {
+	return m.AwayLineupID != nil && *m.AwayLineupID == lineupID
+}
+
+// teamOf returns the team the lineup plays for in the match, if any.
+func (m *match) teamOf(lineupID int64) *int64 {
+	if m.isHome(lineupID) {
+		return m.HomeTeamID
+	}
+	if m.isAway(lineupID) {
+		return m.AwayTeamID
+	}
+	return nil
+}
+
+// lineupIDs returns the IDs of the lineups attached to the match.
+func (m *match) lineupIDs() []int64 {
+	var ids []int64
+	if m.HomeLineupID != nil {
+		ids = append(ids, *m.HomeLineupID)
+	}
+	if m.AwayLineupID != nil {
+		ids = append(ids, *m.AwayLineupID)
+	}
+	return ids
+}
+
+// matchScore computes the running score of the match from its goals.
+func (s *server) matchScore(m *match) (*score, error) {
+	var goals []action
+
+	err := s.db.Collection(actionsTable).Find("match_id", m.MatchID).And("annulled", false).
+		And("action", db.In([]actionType{ACTION_GOAL, ACTION_GOAL_OWN})).All(&goals)
+	if err != nil {
+		return nil, err
+	}
+
+	sc := new(score)
+	for i := range goals {
+		sc.add(m, &goals[i])
+	}
+
+	return sc, nil
+}
+
+// pickedBy reports whether a lineup can play for the team of a side of a
+// match. Any lineup does when the side has no team.
+func pickedBy(l *lineup, teamID *int64) bool {
+	return teamID == nil || (l.TeamID != nil && *l.TeamID == *teamID)
+}
+
+// checkMatchLineups ensures the lineups attached to a match exist, that
+// their `is_local` flag agrees with the side they are attached to and that
+// they are picked by the team playing on that side.
+func (s *server) checkMatchLineups(m *match) error {
+	if m.HomeLineupID != nil && m.AwayLineupID != nil && *m.HomeLineupID == *m.AwayLineupID {
+		return errSameLineups
+	}
+
+	if m.HomeLineupID != nil {
+		found := new(lineup)
+		err := s.db.Collection(lineupsTable).Find("lineup_id", *m.HomeLineupID).One(found)
+		if err == db.ErrNoMoreRows {
+			return errHomeLineupNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if found.IsLocal == nil || !*found.IsLocal {
+			return errHomeLineupNotLocal
+		}
+		if !pickedBy(found, m.HomeTeamID) {
+			return errHomeLineupTeam
+		}
+	}
+
+	if m.AwayLineupID != nil {
+		found := new(lineup)
+		err := s.db.Collection(lineupsTable).Find("lineup_id", *m.AwayLineupID).One(found)
+		if err == db.ErrNoMoreRows {
+			return errAwayLineupNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if found.IsLocal != nil && *found.IsLocal {
+			return errAwayLineupIsLocal
+		}
+		if !pickedBy(found, m.AwayTeamID) {
+			return errAwayLineupTeam
+		}
+	}
+
+	return nil
+}
+
+// checkMatchPlayers ensures none of the players of the given lineups of the
+// match is suspended for it, nor unavailable on its day when unavailable
+// players are kept out of lineups. The lineups are locked until the
+// transaction ends, so their rosters cannot change before the match is
+// stored.
+func (s *server) checkMatchPlayers(tx sqlbuilder.Tx, m *match, lineupIDs []int64) error {
+	if len(lineupIDs) == 0 {
+		return nil
+	}
+
+	// Lock in a fixed order so concurrent updates do not deadlock.
+	sorted := append([]int64{}, lineupIDs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for _, id := range sorted {
+		if _, err := lockLineup(tx, id); err != nil {
+			return err
+		}
+	}
+
+	var roster []lineupPlayer
+
+	err := tx.SelectFrom(lineupPlayersTable).Where(db.Cond{"lineup_id": db.In(lineupIDs)}).
+		OrderBy("lineup_id", "player_id").All(&roster)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(roster))
+	for _, lp := range roster {
+		ids = append(ids, lp.PlayerID)
+	}
+
+	r, err := s.matchRestrictions(tx, m, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if _, err := r.check(id); err != nil {
+			log.WithField("player_id", id).Debug("Player cannot be picked for the match")
+			return err
+		}
+	}
+
+	return nil
+}
+
+func matchLineupsError(c echo.Context, err error) error {
+	switch err {
+	case errSameLineups, errHomeLineupNotFound, errAwayLineupNotFound, errHomeLineupNotLocal, errAwayLineupIsLocal,
+		errHomeLineupTeam, errAwayLineupTeam, errLineupNotFound:
+		log.WithError(err).Debug("Invalid match lineups")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	switch err.(type) {
+	case *suspendedError, *unavailableError:
+		log.WithError(err).Debug("Player cannot be picked")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	if isForeignKeyViolation(err) {
+		log.WithError(err).Debug("Season or team not found")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errMatchReference.Error())
+	}
+
+	if isUniqueViolation(err) {
+		log.WithError(err).Debug("Lineup already attached to a match")
+		return echo.NewHTTPError(http.StatusConflict, errLineupAlreadyPlayed.Error())
+	}
+
+	log.WithError(err).Error("Failed to store match lineups")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+func (s *server) createMatch(c echo.Context) error {
+	req := new(match)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure MatchID is not set.
+	if req.MatchID != 0 {
+		log.WithError(fmt.Errorf("match_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.Status == MATCH_STATUS_INVALID {
+		req.Status = MATCH_STATUS_SCHEDULED
+	}
+
+	if err := s.checkMatchLineups(req); err != nil {
+		return matchLineupsError(c, err)
+	}
+
+	var ret interface{}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		if err := s.checkMatchPlayers(tx, req, req.lineupIDs()); err != nil {
+			return err
+		}
+
+		var err error
+		ret, err = tx.Collection(matchesTable).Insert(req)
+		return err
+	})
+	if err != nil {
+		return matchLineupsError(c, err)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting a match")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &match{
+		MatchID: id,
+	})
+}
+
+func (s *server) getMatch(c echo.Context) error {
+	found := new(match)
+
+	err := s.db.Collection(matchesTable).Find("match_id", getMatchID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, errMatchNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	found.Score, err = s.matchScore(found)
+	if err != nil {
+		log.WithError(err).Error("Failed to compute match score")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+func (s *server) listMatches(c echo.Context) error {
+	filter := db.Cond{}
+	if status := c.QueryParam("status"); status != "" {
+		val, ok := matchStatus_value[status]
+		if !ok || val == 0 {
+			log.WithError(fmt.Errorf("Invalid `status` value")).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `status` value")
+		}
+		filter["status"] = val
+	}
+
+	if str := c.QueryParam("season_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithError(errInvalidSeasonValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidSeasonValue.Error())
+		}
+		filter["season_id"] = id
+	}
+
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	var matches []match
+
+	err = s.db.Collection(matchesTable).Find(filter).OrderBy("match_id").
+		Paginate(limit).Page(page).All(&matches)
+	if err != nil {
+		log.WithError(err).Error("Failed to list matches from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	for i := range matches {
+		matches[i].Score, err = s.matchScore(&matches[i])
+		if err != nil {
+			log.WithError(err).Error("Failed to compute match score")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	return c.JSON(http.StatusOK, &matches)
+}
+
+func (s *server) updateMatch(c echo.Context) error {
+	req := new(match)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	// Ensure MatchID is not set.
+	if req.MatchID != 0 {
+		log.WithError(fmt.Errorf("match_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	found, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, errMatchNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// Validate the lineups as they will look once the update is applied.
+	merged := *found
+	if req.HomeTeamID != nil {
+		merged.HomeTeamID = req.HomeTeamID
+	}
+	if req.AwayTeamID != nil {
+		merged.AwayTeamID = req.AwayTeamID
+	}
+	if req.HomeLineupID != nil {
+		merged.HomeLineupID = req.HomeLineupID
+	}
+	if req.AwayLineupID != nil {
+		merged.AwayLineupID = req.AwayLineupID
+	}
+	if err := s.checkMatchLineups(&merged); err != nil {
+		return matchLineupsError(c, err)
+	}
+
+	// Players are checked again when the match moves to another day or
+	// competition, otherwise only those of the lineups being attached.
+	if req.SeasonID != nil {
+		merged.SeasonID = req.SeasonID
+	}
+	if req.Kickoff != nil {
+		merged.Kickoff = req.Kickoff
+	}
+	if req.Status != MATCH_STATUS_INVALID {
+		merged.Status = req.Status
+	}
+	checked := req.lineupIDs()
+	if req.SeasonID != nil || req.Kickoff != nil {
+		checked = merged.lineupIDs()
+	}
+
+	err = s.tx(func(tx sqlbuilder.Tx) error {
+		if err := s.checkMatchPlayers(tx, &merged, checked); err != nil {
+			return err
+		}
+
+		err := tx.Collection(matchesTable).Find("match_id", found.MatchID).Update(req)
+		if err != nil {
+			return err
+		}
+
+		// Finishing the match, or changing it once finished, changes the
+		// stats of its players.
+		m, err := lockMatch(tx, found.MatchID)
+		if err != nil {
+			return err
+		}
+		return s.refreshMatchStats(tx, m)
+	})
+	if err != nil {
+		return matchLineupsError(c, err)
+	}
+
+	// The match may have finished or moved to another season.
+	s.purgeStandings(found.SeasonID)
+	s.purgeStandings(req.SeasonID)
+	s.purgeLeaderboards(found.SeasonID)
+	if req.SeasonID != nil {
+		s.purgeLeaderboards(req.SeasonID)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteMatch(c echo.Context) error {
+	found, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		return c.NoContent(http.StatusOK)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	err = s.db.Collection(matchesTable).Find("match_id", found.MatchID).Delete()
+	if err != nil {
+		log.WithError(err).Error("Failed to delete match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s.purgeStandings(found.SeasonID)
+	s.purgeLeaderboards(found.SeasonID)
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) getMatchPitch(c echo.Context) error {
+	m, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var at matchTime
+	if str := c.QueryParam("time"); str != "" {
+		if err := at.UnmarshalText([]byte(str)); err != nil {
+			log.WithField("time", str).Debug("Failed to parse `time`")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `time`")
+		}
+	}
+
+	st, actions, err := s.matchState(s.db, m)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match state from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if at.IsZero() {
+		st.replayAll(actions)
+	} else {
+		st.replay(actions, at)
+	}
+
+	res := &pitch{}
+	if m.HomeLineupID != nil {
+		res.Home = st.pitch(*m.HomeLineupID)
+	}
+	if m.AwayLineupID != nil {
+		res.Away = st.pitch(*m.AwayLineupID)
+	}
+
+	return c.JSON(http.StatusOK, res)
+}
+
+func (s *server) getMatchMinutes(c echo.Context) error {
+	m, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	st, actions, err := s.matchState(s.db, m)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match state from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	st.replayAll(actions)
+
+	minutes := []playerMinutes{}
+	for id, n := range st.minutesPlayed() {
+		minutes = append(minutes, playerMinutes{
+			PlayerID: id,
+			LineupID: st.lineup[id],
+			Minutes:  n,
+		})
+	}
+
+	sort.Slice(minutes, func(i, j int) bool {
+		return minutes[i].PlayerID < minutes[j].PlayerID
+	})
+
+	return c.JSON(http.StatusOK, &minutes)
+}
+
+func (s *server) getMatchTimeline(c echo.Context) error {
+	m, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	st, actions, err := s.matchState(s.db, m)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match state from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	if c.QueryParam("with-annulled") == "true" {
+		actions = nil
+
+		err = s.db.Collection(actionsTable).Find("match_id", m.MatchID).
+			OrderBy("clock", "action_id").All(&actions)
+		if err != nil {
+			log.WithError(err).Error("Failed to list actions from the store")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	}
+
+	return c.JSON(http.StatusOK, st.timeline(actions))
+}
+
+func (s *server) getMatchShootout(c echo.Context) error {
+	m, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	st, actions, err := s.matchState(s.db, m)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match state from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	st.replayAll(actions)
+
+	so := st.shootout
+	if so.Kicks == nil {
+		so.Kicks = []penaltyKick{}
+	}
+
+	return c.JSON(http.StatusOK, &so)
+}
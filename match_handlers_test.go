@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchCRUD(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+		{LineupID: int64(2), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(false)},
+		{LineupID: int64(3), Formation: FORMATION_FOUR_THREE_THREE, IsLocal: boolPtr(true)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "`match_id` explictly set on create",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				MatchID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Home lineup is not local",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(2),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       "{\"message\":\"home lineup must have `is_local` set to true\"}",
+		},
+		{
+			Name:   "Away lineup is local",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				AwayLineupID: int64Ptr(3),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       "{\"message\":\"away lineup must have `is_local` set to false\"}",
+		},
+		{
+			Name:   "Create match",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(1),
+				AwayLineupID: int64Ptr(2),
+				Venue:        "Estadio",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1}`,
+		},
+		{
+			Name:   "Lineup already attached to another match",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(1),
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"lineup is already attached to another match"}`,
+		},
+		{
+			Name:   "Create match without lineups",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Venue: "Campo",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":2}`,
+		},
+		{
+			Name:               "Get first match",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"match_id":1,"home_lineup_id":1,"away_lineup_id":2,"venue":"Estadio","status":"MATCH_STATUS_SCHEDULED","score":{"home":0,"away":0}}`,
+		},
+		{
+			Name:   "Attach home lineup to second match",
+			Method: "PUT",
+			Target: "/matches/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(3),
+				Status:       MATCH_STATUS_LIVE,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Local lineup cannot switch sides while attached",
+			Method: "PUT",
+			Target: "/lineups/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				IsLocal: boolPtr(false),
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"lineup is attached to a match on the other side"}`,
+		},
+		{
+			Name:               "List live matches",
+			Method:             "GET",
+			Target:             "/matches?status=MATCH_STATUS_LIVE",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"match_id":2,"home_lineup_id":3,"venue":"Campo","status":"MATCH_STATUS_LIVE","score":{"home":0,"away":0}}]`,
+		},
+		{
+			Name:               "Delete first match",
+			Method:             "DELETE",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Attempt to get first match",
+			Method:             "GET",
+			Target:             "/matches/1",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"match not found"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
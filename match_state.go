@@ -0,0 +1,274 @@
+package main
+
+import (
+	"sort"
+
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+// matchState is the state of a match rebuilt by replaying its actions in
+// order on top of the lineups it started with, under the rules of the game.
+type matchState struct {
+	match   *match
+	players map[int64]*player
+	rules   []rule
+
+	lineup map[int64]int64
+	role   map[int64]lineupRole
+
+	onPitch       map[int64]bool
+	cameOn        map[int64]bool
+	substitutions map[int64]int
+
+	// enteredAt is the minute each player on the pitch came on, minutes the
+	// minutes played by players who have already left it. Stoppage time is
+	// not counted.
+	enteredAt map[int64]uint16
+	minutes   map[int64]uint64
+	clock     matchTime
+
+	// goals are the goals scored so far by ID, lastGoal the last one scored
+	// by each lineup and assisted the goals that already have an assist.
+	goals    map[int64]action
+	lastGoal map[int64]int64
+	assisted map[int64]bool
+
+	// yellows are the bookings of each player and sentOff the players who
+	// have been sent off.
+	yellows map[int64]int
+	sentOff map[int64]bool
+
+	// extraTime is whether the match went to extra time.
+	extraTime bool
+
+	score    score
+	shootout shootout
+}
+
+// matchState loads the state of the match under the rules it is played by.
+func (s *server) matchState(sess sqlbuilder.SQLBuilder, m *match) (*matchState, []action, error) {
+	rules, err := s.rules(sess, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return loadMatchState(sess, m, rules)
+}
+
+// loadMatchState loads the lineups of the match and returns its state at
+// kickoff together with the actions recorded so far, in order.
+func loadMatchState(sess sqlbuilder.SQLBuilder, m *match, rules []rule) (*matchState, []action, error) {
+	st := &matchState{
+		match:         m,
+		players:       map[int64]*player{},
+		rules:         rules,
+		lineup:        map[int64]int64{},
+		role:          map[int64]lineupRole{},
+		onPitch:       map[int64]bool{},
+		cameOn:        map[int64]bool{},
+		substitutions: map[int64]int{},
+		enteredAt:     map[int64]uint16{},
+		minutes:       map[int64]uint64{},
+		goals:         map[int64]action{},
+		lastGoal:      map[int64]int64{},
+		assisted:      map[int64]bool{},
+		yellows:       map[int64]int{},
+		sentOff:       map[int64]bool{},
+	}
+
+	for _, id := range m.lineupIDs() {
+		roster, err := lineupPlayers(sess, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for role, players := range roster {
+			for i := range players {
+				p := &players[i]
+				st.players[p.PlayerID] = p
+				st.lineup[p.PlayerID] = id
+				st.role[p.PlayerID] = role
+				st.onPitch[p.PlayerID] = role == ROLE_STARTER
+				if role == ROLE_STARTER {
+					st.enteredAt[p.PlayerID] = 0
+				}
+			}
+		}
+	}
+
+	var actions []action
+
+	err := sess.SelectFrom(actionsTable).Where("match_id", m.MatchID).And("annulled", false).
+		OrderBy("clock", "action_id").All(&actions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return st, actions, nil
+}
+
+// playerLineup returns the lineup of the match the player is playing for.
+// Players left out of the match sheet do not play for any.
+func (st *matchState) playerLineup(playerID int64) (int64, error) {
+	id, ok := st.lineup[playerID]
+	if !ok || st.role[playerID] == ROLE_UNUSED {
+		return 0, errPlayerNotInLineups
+	}
+	return id, nil
+}
+
+// check validates an action against the current state of the match.
+func (st *matchState) check(a *action) error {
+	for _, r := range st.rules {
+		if err := r.check(st, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply moves the state of the match forward past the action and the ones
+// derived from it, which are returned.
+func (st *matchState) apply(a *action) []action {
+	if st.clock.before(a.Time) {
+		st.clock = a.Time
+	}
+
+	switch a.Time.period {
+	case PERIOD_EXTRA_TIME_FIRST_HALF, PERIOD_EXTRA_TIME_SECOND_HALF:
+		st.extraTime = true
+	}
+
+	switch a.Type {
+	case ACTION_SUBSTITUTION:
+		st.leave(a.PlayerID, a.Time)
+		st.onPitch[*a.SubstituteID] = true
+		st.cameOn[*a.SubstituteID] = true
+		st.enteredAt[*a.SubstituteID] = a.Time.minute
+		st.substitutions[a.LineupID]++
+	case ACTION_CARD_YELLOW:
+		st.yellows[a.PlayerID]++
+	case ACTION_CARD_RED:
+		st.leave(a.PlayerID, a.Time)
+		st.sentOff[a.PlayerID] = true
+	case ACTION_GOAL:
+		st.goals[a.ActionID] = *a
+		st.lastGoal[a.LineupID] = a.ActionID
+		st.score.add(st.match, a)
+	case ACTION_GOAL_OWN:
+		st.score.add(st.match, a)
+	case ACTION_ASSIST:
+		if a.GoalID != nil {
+			st.assisted[*a.GoalID] = true
+		}
+	case ACTION_PENALTY_SCORED, ACTION_PENALTY_SAVED, ACTION_PENALTY_MISSED:
+		st.shootout.kick(st.match, a)
+	}
+
+	var derived []action
+	for _, r := range st.rules {
+		for _, d := range r.derive(st, a) {
+			d := d
+			derived = append(derived, d)
+			derived = append(derived, st.apply(&d)...)
+		}
+	}
+
+	return derived
+}
+
+func (st *matchState) leave(playerID int64, at matchTime) {
+	if !st.onPitch[playerID] {
+		return
+	}
+
+	minute := at.minute
+	if at.period == PERIOD_PENALTIES {
+		minute = st.end()
+	}
+
+	st.onPitch[playerID] = false
+	st.minutes[playerID] += uint64(minute - st.enteredAt[playerID])
+	delete(st.enteredAt, playerID)
+}
+
+// end returns the last minute of play of the match, which depends on whether
+// it went to extra time. Penalty shootouts are not counted.
+func (st *matchState) end() uint16 {
+	if st.extraTime {
+		return periodEnd[PERIOD_EXTRA_TIME_SECOND_HALF]
+	}
+	return periodEnd[PERIOD_SECOND_HALF]
+}
+
+// minutesPlayed returns the minutes played by every player who took the
+// pitch, counting those still on it until the end of the match.
+func (st *matchState) minutesPlayed() map[int64]uint64 {
+	end := st.end()
+
+	played := map[int64]uint64{}
+	for id, n := range st.minutes {
+		played[id] = n
+	}
+	for id, at := range st.enteredAt {
+		played[id] += uint64(end - at)
+	}
+
+	return played
+}
+
+// validate replays the actions checking each of them against the state of
+// the match right before it.
+func (st *matchState) validate(actions []action) error {
+	for i := range actions {
+		if err := st.check(&actions[i]); err != nil {
+			return err
+		}
+		st.apply(&actions[i])
+	}
+	return nil
+}
+
+// replay applies the actions happening up to the given time and returns how
+// many of them it applied.
+func (st *matchState) replay(actions []action, until matchTime) int {
+	for i := range actions {
+		if until.before(actions[i].Time) {
+			return i
+		}
+		st.apply(&actions[i])
+	}
+	return len(actions)
+}
+
+// sortActions sorts the actions in the order they happened.
+func sortActions(actions []action) {
+	sort.SliceStable(actions, func(i, j int) bool {
+		if actions[i].Time != actions[j].Time {
+			return actions[i].Time.before(actions[j].Time)
+		}
+		return actions[i].ActionID < actions[j].ActionID
+	})
+}
+
+// replayAll applies every action of the match.
+func (st *matchState) replayAll(actions []action) {
+	for i := range actions {
+		st.apply(&actions[i])
+	}
+}
+
+// pitch returns the players of the lineup currently on the pitch.
+func (st *matchState) pitch(lineupID int64) []player {
+	players := []player{}
+	for id, on := range st.onPitch {
+		if on && st.lineup[id] == lineupID {
+			players = append(players, *st.players[id])
+		}
+	}
+
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].PlayerID < players[j].PlayerID
+	})
+
+	return players
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/apex/log"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+const playerMatchStatsTable = "player_match_stats"
+
+// playerMatchStats is what a player did in a finished match. They are kept
+// up to date as the match changes so player statistics are a sum over them.
+type playerMatchStats struct {
+	PlayerID    int64  `db:"player_id"`
+	MatchID     int64  `db:"match_id"`
+	LineupID    int64  `db:"lineup_id"`
+	Appeared    bool   `db:"appeared"`
+	Started     bool   `db:"started"`
+	Minutes     uint64 `db:"minutes"`
+	Goals       int    `db:"goals"`
+	Assists     int    `db:"assists"`
+	YellowCards int    `db:"yellow_cards"`
+	RedCards    int    `db:"red_cards"`
+}
+
+// matchStats computes the stats of the players of the match once all its
+// actions have been applied to the state. Players who neither took the pitch
+// nor were involved in any action are left out.
+func matchStats(st *matchState, actions []action) []playerMatchStats {
+	byPlayer := map[int64]*playerMatchStats{}
+	get := func(id int64) *playerMatchStats {
+		ps, ok := byPlayer[id]
+		if !ok {
+			ps = &playerMatchStats{
+				PlayerID: id,
+				MatchID:  st.match.MatchID,
+				LineupID: st.lineup[id],
+			}
+			byPlayer[id] = ps
+		}
+		return ps
+	}
+
+	for id, minutes := range st.minutesPlayed() {
+		ps := get(id)
+		ps.Appeared = true
+		ps.Started = st.role[id] == ROLE_STARTER
+		ps.Minutes = minutes
+	}
+
+	for _, a := range actions {
+		switch a.Type {
+		case ACTION_GOAL:
+			get(a.PlayerID).Goals++
+		case ACTION_ASSIST:
+			get(a.PlayerID).Assists++
+		}
+	}
+
+	// Bookings and dismissals include the ones derived by the rules of the
+	// game, like a red card following a second yellow.
+	for id, n := range st.yellows {
+		get(id).YellowCards = n
+	}
+	for id, off := range st.sentOff {
+		if off {
+			get(id).RedCards = 1
+		}
+	}
+
+	stats := make([]playerMatchStats, 0, len(byPlayer))
+	for _, ps := range byPlayer {
+		stats = append(stats, *ps)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].PlayerID < stats[j].PlayerID
+	})
+
+	return stats
+}
+
+// refreshMatchStats replaces the stats of the players of the match. Only
+// finished matches count towards player statistics, they are marked as
+// computed so the backfill skips them even when no player has any.
+func (s *server) refreshMatchStats(tx sqlbuilder.Tx, m *match) error {
+	err := tx.Collection(playerMatchStatsTable).Find("match_id", m.MatchID).Delete()
+	if err != nil {
+		return err
+	}
+
+	computedAt := db.Raw("NULL")
+	if m.Status == MATCH_STATUS_FINISHED {
+		computedAt = db.Raw("NOW()")
+	}
+
+	err = tx.Collection(matchesTable).Find("match_id", m.MatchID).Update(map[string]interface{}{
+		"stats_computed_at": computedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	if m.Status != MATCH_STATUS_FINISHED {
+		return nil
+	}
+
+	st, actions, err := s.matchState(tx, m)
+	if err != nil {
+		return err
+	}
+
+	st.replayAll(actions)
+
+	for _, ps := range matchStats(st, actions) {
+		if ps.LineupID == 0 {
+			continue
+		}
+
+		_, err := tx.Collection(playerMatchStatsTable).Insert(&ps)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refreshLineupMatchStats refreshes the stats of the match the lineup is
+// attached to, if any, after a change to its players. It returns the matches
+// refreshed.
+func (s *server) refreshLineupMatchStats(tx sqlbuilder.Tx, lineupID int64) ([]match, error) {
+	var matches []match
+
+	err := tx.SelectFrom(matchesTable).
+		Where("home_lineup_id = ? OR away_lineup_id = ?", lineupID, lineupID).All(&matches)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range matches {
+		if err := s.refreshMatchStats(tx, &matches[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return matches, nil
+}
+
+// backfillMatchStats computes the stats of the finished matches they were
+// never computed for, like those finished before stats were kept.
+func (s *server) backfillMatchStats() error {
+	var matches []match
+
+	err := s.db.SelectFrom(matchesTable).Where("status", MATCH_STATUS_FINISHED).
+		And(db.Raw("stats_computed_at IS NULL")).
+		OrderBy("match_id").All(&matches)
+	if err != nil {
+		return err
+	}
+
+	for i := range matches {
+		err := s.tx(func(tx sqlbuilder.Tx) error {
+			m, err := lockMatch(tx, matches[i].MatchID)
+			if err != nil {
+				return err
+			}
+			return s.refreshMatchStats(tx, m)
+		})
+		if err != nil {
+			return err
+		}
+
+		s.purgeLeaderboards(matches[i].SeasonID)
+	}
+
+	if len(matches) > 0 {
+		log.WithField("matches", len(matches)).Info("Backfilled player match stats")
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScoreAdd(t *testing.T) {
+	r := require.New(t)
+
+	m := &match{HomeLineupID: int64Ptr(1), AwayLineupID: int64Ptr(2)}
+
+	sc := new(score)
+	for _, a := range []action{
+		{LineupID: 1, Type: ACTION_GOAL},
+		{LineupID: 2, Type: ACTION_GOAL},
+		{LineupID: 2, Type: ACTION_GOAL_OWN},
+		{LineupID: 1, Type: ACTION_CARD_YELLOW},
+		// Recorded for a lineup that has since been replaced.
+		{LineupID: 3, Type: ACTION_GOAL},
+		{LineupID: 3, Type: ACTION_GOAL_OWN},
+	} {
+		sc.add(m, &a)
+	}
+
+	r.Equal(&score{Home: 2, Away: 1}, sc)
+}
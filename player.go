@@ -7,11 +7,27 @@ import (
 
 type player struct {
 	PlayerID    int64    `json:"player_id,omitempty" db:"player_id,omitempty"`
+	TeamID      *int64   `json:"team_id,omitempty" db:"team_id,omitempty"`
 	DisplayName string   `json:"display_name,omitempty" db:"display_name,omitempty"`
 	Number      int      `json:"number,omitempty" db:"number,omitempty"`
 	Position    position `json:"position,omitempty" db:"position,omitempty"`
 }
 
+// playerStats are the totals of a player across the finished matches they
+// played, optionally only those of a season or a competition.
+type playerStats struct {
+	PlayerID      int64  `json:"player_id" db:"-"`
+	SeasonID      *int64 `json:"season_id,omitempty" db:"-"`
+	CompetitionID *int64 `json:"competition_id,omitempty" db:"-"`
+	Appearances   int    `json:"appearances" db:"appearances"`
+	Starts        int    `json:"starts" db:"starts"`
+	Minutes       uint64 `json:"minutes" db:"minutes"`
+	Goals         int    `json:"goals" db:"goals"`
+	Assists       int    `json:"assists" db:"assists"`
+	YellowCards   int    `json:"yellow_cards" db:"yellow_cards"`
+	RedCards      int    `json:"red_cards" db:"red_cards"`
+}
+
 type position int
 
 func (p position) String() string {
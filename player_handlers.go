@@ -1,12 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/apex/log"
 	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
 )
 
 func playerID(next echo.HandlerFunc) echo.HandlerFunc {
@@ -35,6 +37,11 @@ func getPlayerID(c echo.Context) (id int64) {
 
 const playersTable = "players"
 
+var (
+	errPlayerNotFound = errors.New("player not found")
+	errTeamChange     = errors.New("`team_id` cannot be updated, transfer the player instead")
+)
+
 func (s *server) createPlayer(c echo.Context) error {
 	req := new(player)
 	if err := c.Bind(req); err != nil {
@@ -49,6 +56,10 @@ func (s *server) createPlayer(c echo.Context) error {
 	}
 
 	ret, err := s.db.Collection(playersTable).Insert(req)
+	if isForeignKeyViolation(err) {
+		log.WithError(err).Debug("team not found")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errTeamNotFound.Error())
+	}
 	if err != nil {
 		log.WithError(err).Error("Failed to insert player in the store")
 		return c.NoContent(http.StatusInternalServerError)
@@ -65,45 +76,142 @@ func (s *server) createPlayer(c echo.Context) error {
 	})
 }
 
+func (s *server) getPlayer(c echo.Context) error {
+	found := new(player)
+
+	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+// getPlayerStats sums the stats the player got in each finished match, see
+// refreshMatchStats. The `season_id` and `competition_id` params narrow them
+// down to the matches of a season or a competition.
+func (s *server) getPlayerStats(c echo.Context) error {
+	found := new(player)
+
+	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	stats := &playerStats{PlayerID: found.PlayerID}
+
+	q := s.db.Select(
+		db.Raw("COUNT(*) FILTER (WHERE ps.appeared) AS appearances"),
+		db.Raw("COUNT(*) FILTER (WHERE ps.started) AS starts"),
+		db.Raw("COALESCE(SUM(ps.minutes), 0) AS minutes"),
+		db.Raw("COALESCE(SUM(ps.goals), 0) AS goals"),
+		db.Raw("COALESCE(SUM(ps.assists), 0) AS assists"),
+		db.Raw("COALESCE(SUM(ps.yellow_cards), 0) AS yellow_cards"),
+		db.Raw("COALESCE(SUM(ps.red_cards), 0) AS red_cards"),
+	).From(fmt.Sprintf("%s AS ps", playerMatchStatsTable)).
+		Join(fmt.Sprintf("%s AS m", matchesTable)).On("m.match_id = ps.match_id").
+		Where("ps.player_id", found.PlayerID)
+
+	if str := c.QueryParam("season_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithError(errInvalidSeasonValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidSeasonValue.Error())
+		}
+		stats.SeasonID = &id
+		q = q.And("m.season_id", id)
+	}
+
+	if str := c.QueryParam("competition_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithError(errInvalidCompetitionValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidCompetitionValue.Error())
+		}
+		stats.CompetitionID = &id
+		q = q.And(db.Raw(fmt.Sprintf("m.season_id IN (SELECT season_id FROM %s WHERE competition_id = ?)", seasonsTable), id))
+	}
+
+	err = q.One(stats)
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player stats from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// listPlayers returns the players matching the `position`, `team_id` and
+// `availability` params. Availability is checked on the day given by the
+// `date` param, today by default.
 func (s *server) listPlayers(c echo.Context) error {
-	var filter []interface{}
+	filter := db.Cond{}
 	if pos := c.QueryParam("position"); pos != "" {
 		val, ok := position_value[pos]
 		if !ok || val == 0 {
 			log.WithError(fmt.Errorf("Invalid `position` value")).Error("Invalid request")
 			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `position` value")
 		}
-		filter = append(filter, "position", val)
+		filter["position"] = val
 	}
 
-	var err error
-
-	limit := uint(10)
-	if limitStr := c.QueryParam("limit"); limitStr != "" {
-		limit, err = toUint(limitStr)
+	if str := c.QueryParam("team_id"); str != "" {
+		id, err := strconv.ParseInt(str, 10, 64)
 		if err != nil {
-			log.WithError(fmt.Errorf("`limit` was invalid")).Error("Invalid request")
-			return c.NoContent(http.StatusBadRequest)
+			log.WithError(errInvalidTeamValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidTeamValue.Error())
 		}
+		filter["team_id"] = id
 	}
 
-	if limit > 100 {
-		log.WithError(fmt.Errorf("`limit` cannot be greater than 100")).Error("Invalid request")
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, "`limit` cannot be greater than 100")
-	}
+	conds := []interface{}{filter}
 
-	page := uint(1)
-	if pageStr := c.QueryParam("page"); pageStr != "" {
-		page, err = toUint(pageStr)
-		if err != nil {
-			log.WithError(fmt.Errorf("page was invalid")).Error("Invalid request")
-			return c.NoContent(http.StatusBadRequest)
+	if str := c.QueryParam("availability"); str != "" {
+		val, ok := availabilityStatus_value[str]
+		if !ok || val == 0 {
+			log.WithError(errInvalidAvailabilityValue).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidAvailabilityValue.Error())
+		}
+
+		on := today()
+		if str := c.QueryParam("date"); str != "" {
+			var err error
+			on, err = parseDate(str)
+			if err != nil {
+				log.WithError(err).Error("Invalid request")
+				return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidAvailabilityDate.Error())
+			}
 		}
+
+		const covering = "SELECT 1 FROM player_availability a WHERE a.player_id = players.player_id" +
+			" AND a.start_date <= ? AND (a.expected_return IS NULL OR a.expected_return > ?)"
+
+		if availabilityStatus(val) == AVAILABILITY_AVAILABLE {
+			conds = append(conds, db.Raw("NOT EXISTS ("+covering+")", on, on))
+		} else {
+			conds = append(conds, db.Raw("EXISTS ("+covering+" AND a.status = ?)", on, on, val))
+		}
+	}
+
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
 	}
 
 	var players []player
 
-	err = s.db.Collection(playersTable).Find(filter...).OrderBy("player_id").
+	err = s.db.Collection(playersTable).Find(conds...).OrderBy("player_id").
 		Paginate(limit).Page(page).All(&players)
 	if err != nil {
 		log.WithError(err).Error("Failed to list players from the store")
@@ -113,14 +221,6 @@ func (s *server) listPlayers(c echo.Context) error {
 	return c.JSON(http.StatusOK, &players)
 }
 
-func toUint(str string) (uint, error) {
-	val, err := strconv.ParseUint(str, 10, 64)
-	if err != nil {
-		return 0, err
-	}
-	return uint(val), nil
-}
-
 func (s *server) updatePlayer(c echo.Context) error {
 	req := new(player)
 	if err := c.Bind(req); err != nil {
@@ -133,6 +233,12 @@ func (s *server) updatePlayer(c echo.Context) error {
 		return c.NoContent(http.StatusBadRequest)
 	}
 
+	// Players change teams through transfers, which keep their history.
+	if req.TeamID != nil {
+		log.WithError(errTeamChange).Debug("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errTeamChange.Error())
+	}
+
 	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).Update(req)
 	if err != nil {
 		log.WithError(err).Error("Failed to update player from the store")
@@ -77,6 +77,33 @@ func TestPlayerCRUD(t *testing.T) {
 			ExpectedStatusCode: http.StatusOK,
 			ExpectedBody:       `[{"player_id":1,"display_name":"Foo","number":1,"position":"POSITION_GOALKEEPER"},{"player_id":2,"display_name":"Bar","number":7,"position":"POSITION_STRIKER"}]`,
 		},
+		{
+			Name:               "Get second player",
+			Method:             "GET",
+			Target:             "/players/2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":2,"display_name":"Bar","number":7,"position":"POSITION_STRIKER"}`,
+		},
+		{
+			Name:               "Get unknown player",
+			Method:             "GET",
+			Target:             "/players/3",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player not found"}`,
+		},
+		{
+			Name:   "Change the team of a player",
+			Method: "PUT",
+			Target: "/players/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				TeamID: int64Ptr(1),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"` + "`team_id`" + ` cannot be updated, transfer the player instead"}`,
+		},
 		{
 			Name:               "List only strikers",
 			Method:             "GET",
@@ -130,6 +157,13 @@ func TestPlayerCRUD(t *testing.T) {
 			Target:             "/players/1",
 			ExpectedStatusCode: http.StatusOK,
 		},
+		{
+			Name:               "Attempt to get deleted player",
+			Method:             "GET",
+			Target:             "/players/1",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player not found"}`,
+		},
 		{
 			Name:               "Delete player 2",
 			Method:             "DELETE",
@@ -173,3 +207,356 @@ func TestPlayerCRUD(t *testing.T) {
 		})
 	}
 }
+
+func TestPlayerStats(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	for _, c := range []competition{
+		{CompetitionID: int64(1), Name: "Liga"},
+		{CompetitionID: int64(2), Name: "Copa"},
+	} {
+		_, err := s.db.Collection(competitionsTable).Insert(&c)
+		r.Nil(err)
+	}
+
+	for _, se := range []season{
+		{SeasonID: int64(1), CompetitionID: int64(1), Name: "2019/20", Rounds: 2},
+		{SeasonID: int64(2), CompetitionID: int64(1), Name: "2020/21", Rounds: 2},
+		{SeasonID: int64(3), CompetitionID: int64(2), Name: "2020", Rounds: 1},
+	} {
+		_, err := s.db.Collection(seasonsTable).Insert(&se)
+		r.Nil(err)
+	}
+
+	for _, p := range []player{
+		{PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(2), DisplayName: "Bar", Number: 1, Position: POSITION_GOALKEEPER},
+		{PlayerID: int64(3), DisplayName: "Baz", Number: 19, Position: POSITION_STRIKER},
+		{PlayerID: int64(4), DisplayName: "Qux", Number: 10, Position: POSITION_MIDDLEFIELD},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	// The same sides meet in a match of each season and in a friendly.
+	for i, seasonID := range []*int64{int64Ptr(1), int64Ptr(2), int64Ptr(3), nil} {
+		home, away := int64(2*i+1), int64(2*i+2)
+
+		for _, l := range []lineup{
+			{LineupID: home, IsLocal: boolPtr(true)},
+			{LineupID: away, IsLocal: boolPtr(false)},
+		} {
+			_, err := s.db.Collection(lineupsTable).Insert(&l)
+			r.Nil(err)
+		}
+
+		for _, lp := range []lineupPlayer{
+			{LineupID: home, PlayerID: int64(1), Role: ROLE_STARTER},
+			{LineupID: home, PlayerID: int64(4), Role: ROLE_STARTER},
+			{LineupID: home, PlayerID: int64(3), Role: ROLE_SUBSTITUTE},
+			{LineupID: away, PlayerID: int64(2), Role: ROLE_STARTER},
+		} {
+			_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+			r.Nil(err)
+		}
+
+		_, err := s.db.Collection(matchesTable).Insert(&match{
+			MatchID:      int64(i + 1),
+			SeasonID:     seasonID,
+			HomeLineupID: int64Ptr(home),
+			AwayLineupID: int64Ptr(away),
+			Status:       MATCH_STATUS_LIVE,
+		})
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Score in the first season",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(10, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:   "Assist in the first season",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(4),
+				Type:     ACTION_ASSIST,
+				Time:     at(10, 0),
+				GoalID:   int64Ptr(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":2}`,
+		},
+		{
+			Name:   "Booked in the first season",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(20, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":3}`,
+		},
+		{
+			Name:   "Substituted in the first season",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID:     int64(1),
+				Type:         ACTION_SUBSTITUTION,
+				Time:         at(60, 0),
+				SubstituteID: int64Ptr(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":4}`,
+		},
+		{
+			Name:               "Stats do not count unfinished matches",
+			Method:             "GET",
+			Target:             "/players/1/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"appearances":0,"starts":0,"minutes":0,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:   "Finish match 1",
+			Method: "PUT",
+			Target: "/matches/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "First booking in the second season",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":5}`,
+		},
+		{
+			Name:   "Second booking in the second season",
+			Method: "POST",
+			Target: "/matches/2/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_YELLOW,
+				Time:     at(50, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":6}`,
+		},
+		{
+			Name:   "Finish match 2",
+			Method: "PUT",
+			Target: "/matches/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Score in the cup",
+			Method: "POST",
+			Target: "/matches/3/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(5, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":7}`,
+		},
+		{
+			Name:   "Finish match 3",
+			Method: "PUT",
+			Target: "/matches/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Score in a friendly not finished",
+			Method: "POST",
+			Target: "/matches/4/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_GOAL,
+				Time:     at(5, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":8}`,
+		},
+		{
+			Name:               "Stats across every season",
+			Method:             "GET",
+			Target:             "/players/1/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"appearances":3,"starts":3,"minutes":200,"goals":2,"assists":0,"yellow_cards":3,"red_cards":1}`,
+		},
+		{
+			Name:               "Stats of a season",
+			Method:             "GET",
+			Target:             "/players/1/stats?season_id=1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"season_id":1,"appearances":1,"starts":1,"minutes":60,"goals":1,"assists":0,"yellow_cards":1,"red_cards":0}`,
+		},
+		{
+			Name:               "Stats of a season with a dismissal",
+			Method:             "GET",
+			Target:             "/players/1/stats?season_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"season_id":2,"appearances":1,"starts":1,"minutes":50,"goals":0,"assists":0,"yellow_cards":2,"red_cards":1}`,
+		},
+		{
+			Name:               "Stats of a competition",
+			Method:             "GET",
+			Target:             "/players/1/stats?competition_id=1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"competition_id":1,"appearances":2,"starts":2,"minutes":110,"goals":1,"assists":0,"yellow_cards":3,"red_cards":1}`,
+		},
+		{
+			Name:               "Stats of another competition",
+			Method:             "GET",
+			Target:             "/players/1/stats?competition_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"competition_id":2,"appearances":1,"starts":1,"minutes":90,"goals":1,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:               "Stats of the substitute",
+			Method:             "GET",
+			Target:             "/players/3/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":3,"appearances":1,"starts":0,"minutes":30,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:               "Stats of the assistant",
+			Method:             "GET",
+			Target:             "/players/4/stats",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":4,"appearances":3,"starts":3,"minutes":270,"goals":0,"assists":1,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:   "Annul the goal in the cup",
+			Method: "DELETE",
+			Target: "/matches/3/actions/7",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Handball",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Stats after annulling a goal",
+			Method:             "GET",
+			Target:             "/players/1/stats?competition_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"competition_id":2,"appearances":1,"starts":1,"minutes":90,"goals":0,"assists":0,"yellow_cards":0,"red_cards":0}`,
+		},
+		{
+			Name:               "Invalid `season_id` filter",
+			Method:             "GET",
+			Target:             "/players/1/stats?season_id=foo",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`season_id`" + ` value"}`,
+		},
+		{
+			Name:               "Invalid `competition_id` filter",
+			Method:             "GET",
+			Target:             "/players/1/stats?competition_id=foo",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`competition_id`" + ` value"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+var (
+	errPlayerNotOnPitch    = errors.New("player coming off is not on the pitch")
+	errNotASubstitute      = errors.New("player coming on is not a substitute of the lineup")
+	errSubstituteUsed      = errors.New("player coming on has already been used")
+	errMaxSubstitutions    = errors.New("lineup has reached maximum substitutions")
+	errInvalidSubstitution = errors.New("`substitute_id` must be set only on substitutions")
+	errInvalidGoalLink     = errors.New("`goal_id` must be set only on assists")
+	errAssistWithoutGoal   = errors.New("assist must belong to a goal of the same lineup")
+	errSelfAssist          = errors.New("player cannot assist their own goal")
+	errGoalAlreadyAssisted = errors.New("goal has already been assisted")
+	errPlayerSentOff       = errors.New("player has been sent off")
+	errInvalidGoalkeeper   = errors.New("`goalkeeper_id` must be set only on penalty kicks")
+	errKickOutsideShootout = errors.New("penalty kicks must be recorded at `PEN` time")
+	errNotAShootoutAction  = errors.New("only penalty kicks and cards can be recorded during the shootout")
+	errScoreNotLevel       = errors.New("shootout can only take place on a level score")
+	errShootoutDecided     = errors.New("shootout has already been decided")
+	errKickerNotOnPitch    = errors.New("kicker is not on the pitch")
+	errGoalkeeperNotFacing = errors.New("goalkeeper must be on the pitch for the other lineup")
+	errKickOutOfTurn       = errors.New("lineup is not due to take the next kick")
+)
+
+// rule is a rule of the game enforced while the actions of a match are
+// replayed. check rejects an action breaking the rule given the state of the
+// match right before it, and derive returns the actions that follow from an
+// action once it has been applied.
+type rule interface {
+	check(st *matchState, a *action) error
+	derive(st *matchState, a *action) []action
+}
+
+// rules returns the rules of the game the match is played by. Matches of a
+// season allow the substitutions set by their competition.
+func (s *server) rules(sess sqlbuilder.SQLBuilder, m *match) ([]rule, error) {
+	max := s.config.maxSubstitutions
+
+	if m.SeasonID != nil {
+		var found competition
+
+		err := sess.Select("c.max_substitutions").From(fmt.Sprintf("%s AS c", competitionsTable)).
+			Join(fmt.Sprintf("%s AS se", seasonsTable)).On("se.competition_id = c.competition_id").
+			Where("se.season_id", *m.SeasonID).One(&found)
+		if err != nil && err != db.ErrNoMoreRows {
+			return nil, err
+		}
+		if found.MaxSubstitutions != nil {
+			max = *found.MaxSubstitutions
+		}
+	}
+
+	return []rule{
+		linkRule{},
+		sentOffRule{},
+		substitutionRule{max: max},
+		assistRule{},
+		secondYellowRule{},
+		shootoutRule{},
+	}, nil
+}
+
+// linkRule ensures actions only point to other players or actions when their
+// type allows it.
+type linkRule struct{}
+
+func (linkRule) check(st *matchState, a *action) error {
+	if a.Type != ACTION_SUBSTITUTION && a.SubstituteID != nil {
+		return errInvalidSubstitution
+	}
+	if a.Type != ACTION_ASSIST && a.GoalID != nil {
+		return errInvalidGoalLink
+	}
+	if !a.Type.isPenaltyKick() && a.GoalkeeperID != nil {
+		return errInvalidGoalkeeper
+	}
+	return nil
+}
+
+func (linkRule) derive(st *matchState, a *action) []action {
+	return nil
+}
+
+// sentOffRule keeps players who have been sent off from taking any further
+// part in the match.
+type sentOffRule struct{}
+
+func (sentOffRule) check(st *matchState, a *action) error {
+	switch a.Type {
+	case ACTION_GOAL, ACTION_GOAL_OWN, ACTION_ASSIST,
+		ACTION_PENALTY_SCORED, ACTION_PENALTY_SAVED, ACTION_PENALTY_MISSED:
+		if st.sentOff[a.PlayerID] {
+			return errPlayerSentOff
+		}
+	case ACTION_SUBSTITUTION:
+		if st.sentOff[a.PlayerID] || (a.SubstituteID != nil && st.sentOff[*a.SubstituteID]) {
+			return errPlayerSentOff
+		}
+	}
+	return nil
+}
+
+func (sentOffRule) derive(st *matchState, a *action) []action {
+	return nil
+}
+
+// substitutionRule ensures substitutions bring on an unused substitute of
+// the lineup in place of a player on the pitch, up to max per lineup.
+type substitutionRule struct {
+	max int
+}
+
+func (r substitutionRule) check(st *matchState, a *action) error {
+	if a.Type != ACTION_SUBSTITUTION {
+		return nil
+	}
+
+	if a.SubstituteID == nil {
+		return errInvalidSubstitution
+	}
+
+	if !st.onPitch[a.PlayerID] {
+		return errPlayerNotOnPitch
+	}
+
+	in := *a.SubstituteID
+	if st.lineup[in] != a.LineupID || st.role[in] != ROLE_SUBSTITUTE {
+		return errNotASubstitute
+	}
+
+	if st.cameOn[in] {
+		return errSubstituteUsed
+	}
+
+	if st.substitutions[a.LineupID] >= r.max {
+		return errMaxSubstitutions
+	}
+
+	return nil
+}
+
+func (substitutionRule) derive(st *matchState, a *action) []action {
+	return nil
+}
+
+// assistRule ensures assists belong to a goal of a teammate that has not
+// been assisted yet. Assists recorded without a goal are linked to the last
+// goal of their lineup.
+type assistRule struct{}
+
+func (assistRule) check(st *matchState, a *action) error {
+	if a.Type != ACTION_ASSIST {
+		return nil
+	}
+
+	if a.GoalID == nil {
+		id, ok := st.lastGoal[a.LineupID]
+		if !ok {
+			return errAssistWithoutGoal
+		}
+		a.GoalID = &id
+	}
+
+	goal, ok := st.goals[*a.GoalID]
+	if !ok || goal.LineupID != a.LineupID {
+		return errAssistWithoutGoal
+	}
+
+	if goal.PlayerID == a.PlayerID {
+		return errSelfAssist
+	}
+
+	if st.assisted[goal.ActionID] {
+		return errGoalAlreadyAssisted
+	}
+
+	return nil
+}
+
+func (assistRule) derive(st *matchState, a *action) []action {
+	return nil
+}
+
+// secondYellowRule sends off players booked for the second time in a match.
+type secondYellowRule struct{}
+
+func (secondYellowRule) check(st *matchState, a *action) error {
+	return nil
+}
+
+func (secondYellowRule) derive(st *matchState, a *action) []action {
+	if a.Type != ACTION_CARD_YELLOW || st.yellows[a.PlayerID] != 2 || st.sentOff[a.PlayerID] {
+		return nil
+	}
+
+	return []action{{
+		MatchID:     a.MatchID,
+		LineupID:    a.LineupID,
+		PlayerID:    a.PlayerID,
+		Type:        ACTION_CARD_RED,
+		Time:        a.Time,
+		DerivedFrom: a.ActionID,
+	}}
+}
+
+// shootoutRule ensures penalty shootouts are played after a draw by players
+// on the pitch, with the lineups taking turns until there is a winner.
+type shootoutRule struct{}
+
+func (shootoutRule) check(st *matchState, a *action) error {
+	inShootout := a.Time.period == PERIOD_PENALTIES
+
+	if !a.Type.isPenaltyKick() {
+		if inShootout && a.Type != ACTION_CARD_YELLOW && a.Type != ACTION_CARD_RED {
+			return errNotAShootoutAction
+		}
+		return nil
+	}
+
+	if !inShootout {
+		return errKickOutsideShootout
+	}
+
+	if st.score.Home != st.score.Away {
+		return errScoreNotLevel
+	}
+
+	if st.shootout.WinnerLineupID != nil {
+		return errShootoutDecided
+	}
+
+	if !st.onPitch[a.PlayerID] {
+		return errKickerNotOnPitch
+	}
+
+	gk := a.GoalkeeperID
+	if gk == nil || !st.onPitch[*gk] || st.lineup[*gk] == a.LineupID {
+		return errGoalkeeperNotFacing
+	}
+
+	if !st.shootout.due(st.match, a.LineupID) {
+		return errKickOutOfTurn
+	}
+
+	return nil
+}
+
+func (shootoutRule) derive(st *matchState, a *action) []action {
+	return nil
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func seasonID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("season_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("season_id", str).Debug("Failed to parse `season_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `season_id`")
+		}
+
+		c.Set("season_id", id)
+
+		return next(c)
+	}
+}
+
+func getSeasonID(c echo.Context) (id int64) {
+	id, _ = c.Get("season_id").(int64)
+	return
+}
+
+const (
+	seasonsTable     = "seasons"
+	seasonTeamsTable = "season_teams"
+)
+
+var (
+	errSeasonNotFound       = errors.New("season not found")
+	errInvalidSeasonValue   = errors.New("Invalid `season_id` value")
+	errInvalidRounds        = errors.New("Invalid `rounds` value")
+	errInvalidTiebreakers   = errors.New("Invalid `tiebreakers` value")
+	errInvalidInterval      = errors.New("Invalid `interval_days` value")
+	errTeamAlreadyInSeason  = errors.New("team is already registered to the season")
+	errTeamNotInSeason      = errors.New("team is not registered to the season")
+	errFixturesGenerated    = errors.New("fixtures of the season were already generated")
+	errNotEnoughSeasonTeams = errors.New("season needs at least two teams")
+)
+
+// lockSeason retrieves the season locking its row until the transaction
+// ends, so registrations and fixture generation are serialized.
+func lockSeason(tx sqlbuilder.Tx, seasonID int64) (*season, error) {
+	found := new(season)
+
+	err := tx.SelectFrom(seasonsTable).Where("season_id", seasonID).
+		Amend(func(query string) string {
+			return query + " FOR UPDATE"
+		}).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errSeasonNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+// hasFixtures reports whether the fixtures of the season were generated.
+// Teams and rounds of a season are frozen from then on.
+func hasFixtures(tx sqlbuilder.Tx, seasonID int64) (bool, error) {
+	n, err := tx.Collection(matchesTable).Find("season_id", seasonID).Count()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func seasonError(c echo.Context, err error) error {
+	switch err {
+	case errSeasonNotFound, errCompetitionNotFound:
+		log.WithError(err).Debug("Not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errTeamNotFound, errTeamNotInSeason, errNotEnoughSeasonTeams:
+		log.WithError(err).Debug("Invalid season teams")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errTeamAlreadyInSeason, errFixturesGenerated:
+		log.WithError(err).Debug("Conflicting season change")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	case errTxConflict:
+		log.WithError(err).Debug("Conflicting season update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+
+	log.WithError(err).Error("Failed to store season")
+	return c.NoContent(http.StatusInternalServerError)
+}
+
+func (s *server) createSeason(c echo.Context) error {
+	req := new(season)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure SeasonID and CompetitionID are not set.
+	if req.SeasonID != 0 || req.CompetitionID != 0 {
+		log.WithError(fmt.Errorf("season_id or competition_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.Rounds < 0 || req.Rounds > maxSeasonRounds {
+		log.WithError(errInvalidRounds).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidRounds.Error())
+	}
+
+	if !req.Tiebreakers.valid() {
+		log.WithError(errInvalidTiebreakers).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidTiebreakers.Error())
+	}
+	if req.Rounds == 0 {
+		req.Rounds = defaultSeasonRounds
+	}
+
+	req.CompetitionID = getCompetitionID(c)
+
+	ret, err := s.db.Collection(seasonsTable).Insert(req)
+	if isForeignKeyViolation(err) {
+		return seasonError(c, errCompetitionNotFound)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to insert season in the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting a season")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &season{
+		SeasonID: id,
+	})
+}
+
+func (s *server) listSeasons(c echo.Context) error {
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	seasons := []season{}
+
+	err = s.db.Collection(seasonsTable).Find("competition_id", getCompetitionID(c)).OrderBy("season_id").
+		Paginate(limit).Page(page).All(&seasons)
+	if err != nil {
+		log.WithError(err).Error("Failed to list seasons from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &seasons)
+}
+
+func (s *server) getSeason(c echo.Context) error {
+	found := new(season)
+
+	err := s.db.Collection(seasonsTable).Find("season_id", getSeasonID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("season_id", getSeasonID(c)).Debug("season not found")
+		return echo.NewHTTPError(http.StatusNotFound, errSeasonNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve season from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+func (s *server) updateSeason(c echo.Context) error {
+	req := new(season)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	// Ensure SeasonID and CompetitionID are not set.
+	if req.SeasonID != 0 || req.CompetitionID != 0 {
+		log.WithError(fmt.Errorf("season_id or competition_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if req.Rounds < 0 || req.Rounds > maxSeasonRounds {
+		log.WithError(errInvalidRounds).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidRounds.Error())
+	}
+
+	if !req.Tiebreakers.valid() {
+		log.WithError(errInvalidTiebreakers).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidTiebreakers.Error())
+	}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		found, err := lockSeason(tx, getSeasonID(c))
+		if err != nil {
+			return err
+		}
+
+		if req.Rounds != 0 && req.Rounds != found.Rounds {
+			generated, err := hasFixtures(tx, found.SeasonID)
+			if err != nil {
+				return err
+			}
+			if generated {
+				return errFixturesGenerated
+			}
+		}
+
+		return tx.Collection(seasonsTable).Find("season_id", found.SeasonID).Update(req)
+	})
+	if err != nil {
+		return seasonError(c, err)
+	}
+
+	id := getSeasonID(c)
+	s.purgeStandings(&id)
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteSeason(c echo.Context) error {
+	id := getSeasonID(c)
+
+	// Purged while the season is still around to find its competition.
+	s.purgeLeaderboards(&id)
+
+	err := s.db.Collection(seasonsTable).Find("season_id", id).Delete()
+	if err != nil {
+		log.WithError(err).Error("Failed to delete season from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s.purgeStandings(&id)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// seasonTeams returns the teams registered to the season.
+func seasonTeams(sess sqlbuilder.SQLBuilder, seasonID int64) ([]team, error) {
+	teams := []team{}
+
+	err := sess.Select("t.*").From(fmt.Sprintf("%s AS t", teamsTable)).
+		Join(fmt.Sprintf("%s AS st", seasonTeamsTable)).
+		On("t.team_id = st.team_id").And("st.season_id", seasonID).
+		OrderBy("t.team_id").All(&teams)
+	if err != nil {
+		return nil, err
+	}
+
+	return teams, nil
+}
+
+func (s *server) addSeasonTeam(c echo.Context) error {
+	req := new(seasonTeam)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if req.TeamID == 0 || (req.SeasonID != 0 && req.SeasonID != getSeasonID(c)) {
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		found, err := lockSeason(tx, getSeasonID(c))
+		if err != nil {
+			return err
+		}
+
+		generated, err := hasFixtures(tx, found.SeasonID)
+		if err != nil {
+			return err
+		}
+		if generated {
+			return errFixturesGenerated
+		}
+
+		_, err = tx.Collection(seasonTeamsTable).Insert(&seasonTeam{
+			SeasonID: found.SeasonID,
+			TeamID:   req.TeamID,
+		})
+		if isUniqueViolation(err) {
+			return errTeamAlreadyInSeason
+		}
+		if isForeignKeyViolation(err) {
+			return errTeamNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return seasonError(c, err)
+	}
+
+	id := getSeasonID(c)
+	s.purgeStandings(&id)
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) listSeasonTeams(c echo.Context) error {
+	found := new(season)
+
+	err := s.db.Collection(seasonsTable).Find("season_id", getSeasonID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("season_id", getSeasonID(c)).Debug("season not found")
+		return echo.NewHTTPError(http.StatusNotFound, errSeasonNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve season from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	teams, err := seasonTeams(s.db, found.SeasonID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list season teams from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &teams)
+}
+
+func (s *server) removeSeasonTeam(c echo.Context) error {
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		found, err := lockSeason(tx, getSeasonID(c))
+		if err != nil {
+			return err
+		}
+
+		generated, err := hasFixtures(tx, found.SeasonID)
+		if err != nil {
+			return err
+		}
+		if generated {
+			return errFixturesGenerated
+		}
+
+		res := tx.Collection(seasonTeamsTable).Find("season_id", found.SeasonID).And("team_id", getTeamID(c))
+		n, err := res.Count()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errTeamNotInSeason
+		}
+
+		return res.Delete()
+	})
+	if err != nil {
+		return seasonError(c, err)
+	}
+
+	id := getSeasonID(c)
+	s.purgeStandings(&id)
+
+	return c.NoContent(http.StatusOK)
+}
+
+// generateFixtures schedules every match of the season between the teams
+// registered to it, see roundRobin. Matches are created without lineups so
+// each team can pick one once the matchday comes.
+func (s *server) generateFixtures(c echo.Context) error {
+	req := new(fixtureSchedule)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	if req.IntervalDays < 0 {
+		log.WithError(errInvalidInterval).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, errInvalidInterval.Error())
+	}
+	if req.IntervalDays == 0 {
+		req.IntervalDays = defaultIntervalDays
+	}
+
+	var fixtures []match
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		fixtures = nil
+
+		found, err := lockSeason(tx, getSeasonID(c))
+		if err != nil {
+			return err
+		}
+
+		generated, err := hasFixtures(tx, found.SeasonID)
+		if err != nil {
+			return err
+		}
+		if generated {
+			return errFixturesGenerated
+		}
+
+		teams, err := seasonTeams(tx, found.SeasonID)
+		if err != nil {
+			return err
+		}
+		if len(teams) < 2 {
+			return errNotEnoughSeasonTeams
+		}
+
+		ids := make([]int64, len(teams))
+		for i := range teams {
+			ids[i] = teams[i].TeamID
+		}
+
+		for day, pairings := range roundRobin(ids, found.Rounds) {
+			var kickoff *time.Time
+			if req.Kickoff != nil {
+				t := req.Kickoff.AddDate(0, 0, day*req.IntervalDays)
+				kickoff = &t
+			}
+
+			for _, f := range pairings {
+				f := f
+				m := match{
+					SeasonID:   &found.SeasonID,
+					Matchday:   day + 1,
+					HomeTeamID: &f.HomeTeamID,
+					AwayTeamID: &f.AwayTeamID,
+					Kickoff:    kickoff,
+					Status:     MATCH_STATUS_SCHEDULED,
+				}
+
+				ret, err := tx.Collection(matchesTable).Insert(&m)
+				if err != nil {
+					return err
+				}
+
+				m.MatchID, err = toInt64(ret)
+				if err != nil {
+					return err
+				}
+
+				fixtures = append(fixtures, m)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return seasonError(c, err)
+	}
+
+	return c.JSON(http.StatusOK, &fixtures)
+}
+
+// listFixtures returns the matches of the season ordered by matchday,
+// optionally only those of the matchday given by the `matchday` param.
+func (s *server) listFixtures(c echo.Context) error {
+	found := new(season)
+
+	err := s.db.Collection(seasonsTable).Find("season_id", getSeasonID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("season_id", getSeasonID(c)).Debug("season not found")
+		return echo.NewHTTPError(http.StatusNotFound, errSeasonNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve season from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	filter := db.Cond{"season_id": found.SeasonID}
+	if str := c.QueryParam("matchday"); str != "" {
+		day, err := strconv.Atoi(str)
+		if err != nil || day < 1 {
+			log.WithError(fmt.Errorf("Invalid `matchday` value")).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `matchday` value")
+		}
+		filter["matchday"] = day
+	}
+
+	fixtures := []match{}
+
+	err = s.db.Collection(matchesTable).Find(filter).OrderBy("matchday", "match_id").All(&fixtures)
+	if err != nil {
+		log.WithError(err).Error("Failed to list fixtures from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &fixtures)
+}
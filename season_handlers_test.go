@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeasonFixtures(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	kickoff := time.Date(2020, time.September, 12, 16, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Create competition",
+			Method: "POST",
+			Target: "/competitions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: competition{
+				Name: "Liga",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"competition_id":1}`,
+		},
+		{
+			Name:   "Create team Foo FC",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Foo FC",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1}`,
+		},
+		{
+			Name:   "Create team Bar United",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Bar United",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":2}`,
+		},
+		{
+			Name:   "Create team Baz City",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Baz City",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":3}`,
+		},
+		{
+			Name:   "`season_id` explictly set on create",
+			Method: "POST",
+			Target: "/competitions/1/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				SeasonID: int64(1),
+				Name:     "2020/21",
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Negative number of rounds",
+			Method: "POST",
+			Target: "/competitions/1/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name:   "2020/21",
+				Rounds: -1,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`rounds`" + ` value"}`,
+		},
+		{
+			Name:   "Too many rounds",
+			Method: "POST",
+			Target: "/competitions/1/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name:   "2020/21",
+				Rounds: maxSeasonRounds + 1,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`rounds`" + ` value"}`,
+		},
+		{
+			Name:   "Create a double round-robin season",
+			Method: "POST",
+			Target: "/competitions/1/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name: "2020/21",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"season_id":1}`,
+		},
+		{
+			Name:   "Create a season of an unknown competition",
+			Method: "POST",
+			Target: "/competitions/2/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name: "2020/21",
+			},
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"competition not found"}`,
+		},
+		{
+			Name:   "Create a single round season",
+			Method: "POST",
+			Target: "/competitions/1/seasons",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name:   "2021/22",
+				Rounds: 1,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"season_id":3}`,
+		},
+		{
+			Name:               "Get season",
+			Method:             "GET",
+			Target:             "/seasons/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"season_id":1,"competition_id":1,"name":"2020/21","rounds":2}`,
+		},
+		{
+			Name:               "List seasons of the competition",
+			Method:             "GET",
+			Target:             "/competitions/1/seasons",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"season_id":1,"competition_id":1,"name":"2020/21","rounds":2},{"season_id":3,"competition_id":1,"name":"2021/22","rounds":1}]`,
+		},
+		{
+			Name:               "Generate fixtures without teams",
+			Method:             "POST",
+			Target:             "/seasons/1/fixtures",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"season needs at least two teams"}`,
+		},
+		{
+			Name:   "Register team 1",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register team 2",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register team 3",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register a team twice",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"team is already registered to the season"}`,
+		},
+		{
+			Name:   "Register an unknown team",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(4),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"team not found"}`,
+		},
+		{
+			Name:               "Withdraw team 3",
+			Method:             "DELETE",
+			Target:             "/seasons/1/teams/3",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Withdraw a team not registered",
+			Method:             "DELETE",
+			Target:             "/seasons/1/teams/3",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"team is not registered to the season"}`,
+		},
+		{
+			Name:               "List season teams",
+			Method:             "GET",
+			Target:             "/seasons/1/teams",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"team_id":1,"name":"Foo FC"},{"team_id":2,"name":"Bar United"}]`,
+		},
+		{
+			Name:   "Negative interval between matchdays",
+			Method: "POST",
+			Target: "/seasons/1/fixtures",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: fixtureSchedule{
+				IntervalDays: -1,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`interval_days`" + ` value"}`,
+		},
+		{
+			Name:   "Generate fixtures",
+			Method: "POST",
+			Target: "/seasons/1/fixtures",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: fixtureSchedule{
+				Kickoff: &kickoff,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"match_id":1,"season_id":1,"matchday":1,"home_team_id":1,"away_team_id":2,"kickoff":"2020-09-12T16:00:00Z","status":"MATCH_STATUS_SCHEDULED"},{"match_id":2,"season_id":1,"matchday":2,"home_team_id":2,"away_team_id":1,"kickoff":"2020-09-19T16:00:00Z","status":"MATCH_STATUS_SCHEDULED"}]`,
+		},
+		{
+			Name:               "Generate fixtures twice",
+			Method:             "POST",
+			Target:             "/seasons/1/fixtures",
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"fixtures of the season were already generated"}`,
+		},
+		{
+			Name:   "Register a team once fixtures are generated",
+			Method: "POST",
+			Target: "/seasons/1/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(3),
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"fixtures of the season were already generated"}`,
+		},
+		{
+			Name:   "Change rounds once fixtures are generated",
+			Method: "PUT",
+			Target: "/seasons/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Rounds: 4,
+			},
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedBody:       `{"message":"fixtures of the season were already generated"}`,
+		},
+		{
+			Name:   "Rename season once fixtures are generated",
+			Method: "PUT",
+			Target: "/seasons/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Name: "2020-21",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register team 1 to the single round season",
+			Method: "POST",
+			Target: "/seasons/3/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register team 2 to the single round season",
+			Method: "POST",
+			Target: "/seasons/3/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Register team 3 to the single round season",
+			Method: "POST",
+			Target: "/seasons/3/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: seasonTeam{
+				TeamID: int64(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Generate fixtures with an odd number of teams",
+			Method:             "POST",
+			Target:             "/seasons/3/fixtures",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"match_id":3,"season_id":3,"matchday":1,"home_team_id":2,"away_team_id":1,"status":"MATCH_STATUS_SCHEDULED"},{"match_id":4,"season_id":3,"matchday":2,"home_team_id":1,"away_team_id":3,"status":"MATCH_STATUS_SCHEDULED"},{"match_id":5,"season_id":3,"matchday":3,"home_team_id":3,"away_team_id":2,"status":"MATCH_STATUS_SCHEDULED"}]`,
+		},
+		{
+			Name:               "List fixtures of a matchday",
+			Method:             "GET",
+			Target:             "/seasons/3/fixtures?matchday=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"match_id":4,"season_id":3,"matchday":2,"home_team_id":1,"away_team_id":3,"status":"MATCH_STATUS_SCHEDULED"}]`,
+		},
+		{
+			Name:               "Invalid `matchday` param",
+			Method:             "GET",
+			Target:             "/seasons/3/fixtures?matchday=0",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`matchday`" + ` value"}`,
+		},
+		{
+			Name:   "Create a lineup of the away team",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				TeamID:        int64Ptr(1),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1}`,
+		},
+		{
+			Name:   "Create a lineup of the home team",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				TeamID:        int64Ptr(2),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":2}`,
+		},
+		{
+			Name:   "Attach a lineup of another team",
+			Method: "PUT",
+			Target: "/matches/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(1),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"home lineup is not picked by the home team"}`,
+		},
+		{
+			Name:   "Attach the lineup of the home team",
+			Method: "PUT",
+			Target: "/matches/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				HomeLineupID: int64Ptr(2),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "List matches of the season",
+			Method:             "GET",
+			Target:             "/matches?season_id=3",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"match_id":3,"season_id":3,"matchday":1,"home_team_id":2,"away_team_id":1,"home_lineup_id":2,"status":"MATCH_STATUS_SCHEDULED","score":{"home":0,"away":0}},{"match_id":4,"season_id":3,"matchday":2,"home_team_id":1,"away_team_id":3,"status":"MATCH_STATUS_SCHEDULED","score":{"home":0,"away":0}},{"match_id":5,"season_id":3,"matchday":3,"home_team_id":3,"away_team_id":2,"status":"MATCH_STATUS_SCHEDULED","score":{"home":0,"away":0}}]`,
+		},
+		{
+			Name:               "Invalid `season_id` filter",
+			Method:             "GET",
+			Target:             "/matches?season_id=foo",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`season_id`" + ` value"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,31 +14,158 @@ import (
 )
 
 const schema = `
+CREATE TABLE IF NOT EXISTS teams (
+    team_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT ''
+);
+
 CREATE TABLE IF NOT EXISTS players (
     player_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
     display_name TEXT NOT NULL DEFAULT '',
     number SMALLINT NOT NULL DEFAULT 0,
     position SMALLINT NOT NULL DEFAULT 0
 );
 
+ALTER TABLE players ADD COLUMN IF NOT EXISTS team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS formations (
+    formation_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    slots JSONB NOT NULL,
+    layout JSONB NOT NULL DEFAULT '[]'
+);
+
 CREATE TABLE IF NOT EXISTS lineups (
     lineup_id SERIAL PRIMARY KEY,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
     is_local BOOL NOT NULL DEFAULT FALSE,
-    formation SMALLINT NOT NULL DEFAULT 0
+    formation INTEGER REFERENCES formations(formation_id)
 );
 
+ALTER TABLE lineups ADD COLUMN IF NOT EXISTS team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS transfers (
+    transfer_id SERIAL PRIMARY KEY,
+    player_id INTEGER NOT NULL REFERENCES players(player_id) ON DELETE CASCADE,
+    from_team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    to_team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    date DATE NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS player_availability (
+    availability_id SERIAL PRIMARY KEY,
+    player_id INTEGER NOT NULL REFERENCES players(player_id) ON DELETE CASCADE,
+    status SMALLINT NOT NULL,
+    start_date DATE NOT NULL,
+    expected_return DATE,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS player_availability_player_id ON player_availability(player_id);
+
 CREATE TABLE IF NOT EXISTS lineup_players (
     lineup_id SERIAL NOT NULL REFERENCES lineups(lineup_id) ON DELETE CASCADE,
     player_id SERIAL NOT NULL REFERENCES players(player_id) ON DELETE CASCADE,
+    team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    role SMALLINT NOT NULL DEFAULT 1,
     PRIMARY KEY(lineup_id, player_id)
+);
+
+ALTER TABLE lineup_players ADD COLUMN IF NOT EXISTS team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL;
+ALTER TABLE lineup_players ADD COLUMN IF NOT EXISTS role SMALLINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS competitions (
+    competition_id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    max_substitutions INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS seasons (
+    season_id SERIAL PRIMARY KEY,
+    competition_id INTEGER NOT NULL REFERENCES competitions(competition_id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    rounds SMALLINT NOT NULL DEFAULT 2,
+    tiebreakers JSONB NOT NULL DEFAULT '[]'
+);
+
+CREATE TABLE IF NOT EXISTS season_teams (
+    season_id INTEGER NOT NULL REFERENCES seasons(season_id) ON DELETE CASCADE,
+    team_id INTEGER NOT NULL REFERENCES teams(team_id) ON DELETE CASCADE,
+    PRIMARY KEY(season_id, team_id)
+);
+
+CREATE TABLE IF NOT EXISTS matches (
+    match_id SERIAL PRIMARY KEY,
+    season_id INTEGER REFERENCES seasons(season_id) ON DELETE SET NULL,
+    matchday SMALLINT NOT NULL DEFAULT 0,
+    home_team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    away_team_id INTEGER REFERENCES teams(team_id) ON DELETE SET NULL,
+    home_lineup_id INTEGER UNIQUE REFERENCES lineups(lineup_id) ON DELETE SET NULL,
+    away_lineup_id INTEGER UNIQUE REFERENCES lineups(lineup_id) ON DELETE SET NULL,
+    kickoff TIMESTAMP WITH TIME ZONE,
+    venue TEXT NOT NULL DEFAULT '',
+    status SMALLINT NOT NULL DEFAULT 0,
+    stats_computed_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE matches ADD COLUMN IF NOT EXISTS stats_computed_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE IF NOT EXISTS actions (
+    action_id SERIAL PRIMARY KEY,
+    match_id INTEGER NOT NULL REFERENCES matches(match_id) ON DELETE CASCADE,
+    lineup_id INTEGER NOT NULL REFERENCES lineups(lineup_id) ON DELETE CASCADE,
+    player_id INTEGER NOT NULL REFERENCES players(player_id) ON DELETE CASCADE,
+    substitute_id INTEGER REFERENCES players(player_id) ON DELETE CASCADE,
+    goal_id INTEGER REFERENCES actions(action_id) ON DELETE CASCADE,
+    goalkeeper_id INTEGER REFERENCES players(player_id) ON DELETE CASCADE,
+    action SMALLINT NOT NULL DEFAULT 0,
+    clock INTEGER NOT NULL DEFAULT 0,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    annulled BOOL NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS player_match_stats (
+    player_id INTEGER NOT NULL REFERENCES players(player_id) ON DELETE CASCADE,
+    match_id INTEGER NOT NULL REFERENCES matches(match_id) ON DELETE CASCADE,
+    lineup_id INTEGER NOT NULL REFERENCES lineups(lineup_id) ON DELETE CASCADE,
+    appeared BOOL NOT NULL DEFAULT FALSE,
+    started BOOL NOT NULL DEFAULT FALSE,
+    minutes INTEGER NOT NULL DEFAULT 0,
+    goals SMALLINT NOT NULL DEFAULT 0,
+    assists SMALLINT NOT NULL DEFAULT 0,
+    yellow_cards SMALLINT NOT NULL DEFAULT 0,
+    red_cards SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY(player_id, match_id)
+);
+
+CREATE INDEX IF NOT EXISTS player_match_stats_match_id ON player_match_stats(match_id);
+
+CREATE TABLE IF NOT EXISTS action_corrections (
+    correction_id SERIAL PRIMARY KEY,
+    action_id INTEGER NOT NULL REFERENCES actions(action_id) ON DELETE CASCADE,
+    annulment BOOL NOT NULL DEFAULT FALSE,
+    changed_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    previous JSONB NOT NULL
 );`
 
 type config struct {
-	databaseURL  string
-	redisURL     string
-	address      string
-	level        int
-	disableCache bool
+	databaseURL          string
+	redisURL             string
+	address              string
+	level                int
+	disableCache         bool
+	benchSize            int
+	maxSubstitutions     int
+	streamHeartbeat      time.Duration
+	streamConnections    int
+	transferInterval     time.Duration
+	redCardSuspension    int
+	yellowCardLimit      int
+	yellowCardSuspension int
+	blockUnavailable     bool
 }
 
 type Option func(*server)
@@ -47,8 +175,18 @@ func EnableWebLogger(s *server) {
 }
 
 type server struct {
-	web *echo.Echo
-	db  sqlbuilder.Database
+	web   *echo.Echo
+	db    sqlbuilder.Database
+	redis *redis.Client
+	// streams is only used by the match event streams. Each open stream
+	// holds one of its connections while blocked waiting for events, so
+	// they are kept apart from the ones serving the rest of the requests.
+	streams *redis.Client
+
+	formations *formationCatalogue
+
+	// now is the wall clock actions are recorded with.
+	now func() time.Time
 
 	config config
 }
@@ -65,9 +203,11 @@ func newServer(config config, opts ...Option) (*server, error) {
 	}
 
 	s := &server{
-		web:    echo.New(),
-		db:     sess,
-		config: config,
+		web:        echo.New(),
+		db:         sess,
+		formations: newFormationCatalogue(legacyFormations),
+		now:        time.Now,
+		config:     config,
 	}
 
 	for _, opt := range opts {
@@ -81,6 +221,9 @@ func newServer(config config, opts ...Option) (*server, error) {
 		return nil, err
 	}
 
+	streamOpts := *redisOpts
+	streamOpts.PoolSize = config.streamConnections
+
 	redisConn := redis.NewClient(redisOpts)
 
 	_, err = redisConn.Ping().Result()
@@ -88,23 +231,94 @@ func newServer(config config, opts ...Option) (*server, error) {
 		return nil, fmt.Errorf("Failed to connect to redis instance: %s", err)
 	}
 
+	s.redis = redisConn
+	s.streams = redis.NewClient(&streamOpts)
+
+	s.web.POST("/teams", s.createTeam)
+	s.web.GET("/teams", s.listTeams, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*5))
+	s.web.GET("/teams/:team_id", s.getTeam, teamID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+	s.web.PUT("/teams/:team_id", s.updateTeam, teamID, invalidate(s.config.disableCache, redisConn))
+	s.web.DELETE("/teams/:team_id", s.deleteTeam, teamID, invalidate(s.config.disableCache, redisConn))
+	s.web.GET("/teams/:team_id/suspensions", s.listTeamSuspensions, teamID)
+
+	s.web.POST("/competitions", s.createCompetition)
+	s.web.GET("/competitions", s.listCompetitions, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*5))
+	s.web.GET("/competitions/:competition_id", s.getCompetition, competitionID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+	s.web.PUT("/competitions/:competition_id", s.updateCompetition, competitionID, invalidate(s.config.disableCache, redisConn))
+	s.web.DELETE("/competitions/:competition_id", s.deleteCompetition, competitionID, invalidate(s.config.disableCache, redisConn))
+
+	s.web.POST("/competitions/:competition_id/seasons", s.createSeason, competitionID)
+	s.web.GET("/competitions/:competition_id/seasons", s.listSeasons, competitionID)
+	s.web.GET("/seasons/:season_id", s.getSeason, seasonID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+	s.web.PUT("/seasons/:season_id", s.updateSeason, seasonID, invalidate(s.config.disableCache, redisConn))
+	s.web.DELETE("/seasons/:season_id", s.deleteSeason, seasonID, invalidate(s.config.disableCache, redisConn))
+
+	s.web.POST("/seasons/:season_id/teams", s.addSeasonTeam, seasonID)
+	s.web.GET("/seasons/:season_id/teams", s.listSeasonTeams, seasonID)
+	s.web.DELETE("/seasons/:season_id/teams/:team_id", s.removeSeasonTeam, seasonID, teamID)
+
+	s.web.POST("/seasons/:season_id/fixtures", s.generateFixtures, seasonID)
+	s.web.GET("/seasons/:season_id/fixtures", s.listFixtures, seasonID)
+	s.web.GET("/seasons/:season_id/standings", s.getStandings, seasonID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+
 	s.web.POST("/players", s.createPlayer)
 	s.web.GET("/players", s.listPlayers, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*5))
-	s.web.PUT("/players/:player_id", s.updatePlayer, playerID)
-	s.web.DELETE("/players/:player_id", s.deletePlayer, playerID)
+	s.web.GET("/players/:player_id", s.getPlayer, playerID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+	s.web.GET("/players/:player_id/stats", s.getPlayerStats, playerID)
+	s.web.GET("/players/:player_id/team", s.getPlayerTeam, playerID)
+	s.web.POST("/players/:player_id/transfers", s.createTransfer, playerID)
+	s.web.GET("/players/:player_id/transfers", s.listPlayerTransfers, playerID)
+	s.web.POST("/players/:player_id/availability", s.createAvailability, playerID)
+	s.web.GET("/players/:player_id/availability", s.listAvailability, playerID)
+	s.web.PUT("/players/:player_id/availability/:availability_id", s.updateAvailability, playerID, availabilityID)
+	s.web.DELETE("/players/:player_id/availability/:availability_id", s.deleteAvailability, playerID, availabilityID)
+	s.web.PUT("/players/:player_id", s.updatePlayer, playerID, invalidate(s.config.disableCache, redisConn))
+	s.web.DELETE("/players/:player_id", s.deletePlayer, playerID, invalidate(s.config.disableCache, redisConn))
+
+	s.web.GET("/leaderboards/:metric", s.getLeaderboard)
+
+	s.web.POST("/formations", s.createFormation)
+	s.web.GET("/formations", s.listFormations, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*5))
+	s.web.GET("/formations/:formation_id", s.getFormation, formationID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
+	s.web.PUT("/formations/:formation_id", s.updateFormation, formationID, invalidate(s.config.disableCache, redisConn))
+	s.web.DELETE("/formations/:formation_id", s.deleteFormation, formationID, invalidate(s.config.disableCache, redisConn))
 
 	s.web.POST("/lineups", s.createLineup)
 	s.web.GET("/lineups/:lineup_id", s.getLineup, lineupID, cache(s.config.disableCache, redisConn, time.Duration(time.Second)*10))
 	s.web.PUT("/lineups/:lineup_id", s.updateLineup, lineupID, invalidate(s.config.disableCache, redisConn))
 	s.web.DELETE("/lineups/:lineup_id", s.deleteLineup, lineupID, invalidate(s.config.disableCache, redisConn))
 
+	s.web.GET("/lineups/:lineup_id/validation", s.validateLineup, lineupID)
+
 	s.web.POST("/lineups/:lineup_id/players", s.addPlayerToLineup, lineupID)
+	s.web.PUT("/lineups/:lineup_id/players", s.replaceLineupPlayers, lineupID)
 	s.web.DELETE("/lineups/:lineup_id/players", s.deletePlayerFromLineup, lineupID)
 
+	s.web.POST("/matches", s.createMatch)
+	s.web.GET("/matches", s.listMatches)
+	s.web.GET("/matches/:match_id", s.getMatch, matchID)
+	s.web.GET("/matches/:match_id/pitch", s.getMatchPitch, matchID)
+	s.web.GET("/matches/:match_id/minutes", s.getMatchMinutes, matchID)
+	s.web.GET("/matches/:match_id/timeline", s.getMatchTimeline, matchID)
+	s.web.GET("/matches/:match_id/shootout", s.getMatchShootout, matchID)
+	s.web.GET("/matches/:match_id/stream", s.getMatchStream, matchID)
+	s.web.PUT("/matches/:match_id", s.updateMatch, matchID)
+	s.web.DELETE("/matches/:match_id", s.deleteMatch, matchID)
+
+	s.web.POST("/matches/:match_id/actions", s.createAction, matchID)
+	s.web.GET("/matches/:match_id/actions", s.listActions, matchID)
+	s.web.GET("/matches/:match_id/actions/:action_id", s.getAction, matchID, actionID)
+	s.web.PUT("/matches/:match_id/actions/:action_id", s.correctAction, matchID, actionID)
+	s.web.DELETE("/matches/:match_id/actions/:action_id", s.annulAction, matchID, actionID)
+	s.web.GET("/matches/:match_id/actions/:action_id/corrections", s.listActionCorrections, matchID, actionID)
+
 	return s, nil
 }
 
 func (s *server) start() {
+	go s.applyTransfersEvery(s.config.transferInterval)
+	go s.watchFormations()
+
 	s.web.Logger.Fatal(s.web.Start(s.config.address))
 }
 
@@ -116,5 +330,30 @@ func (s *server) init() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := s.seedFormations(); err != nil {
+		return err
+	}
+	if err := s.applyTransfers(); err != nil {
+		return err
+	}
+	return s.backfillMatchStats()
+}
+
+// maxTxRetries is how many times a conflicting transaction is attempted
+// before giving up.
+const maxTxRetries = 3
+
+var errTxConflict = errors.New("conflicting concurrent update, please retry")
+
+// tx runs fn inside a transaction, retrying it when it is aborted because of
+// a concurrent one.
+func (s *server) tx(fn func(tx sqlbuilder.Tx) error) error {
+	for i := 0; i < maxTxRetries; i++ {
+		err := s.db.Tx(nil, fn)
+		if !isTxConflict(err) {
+			return err
+		}
+		log.WithError(err).Debug("Retrying conflicting transaction")
+	}
+	return errTxConflict
 }
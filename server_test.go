@@ -50,5 +50,19 @@ func testServer() *server {
 		log.WithError(err).Fatal("Failed to create database schema")
 	}
 
+	err = s.redis.FlushDB().Err()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to flush redis database")
+	}
+
 	return s
 }
+
+// at returns the match time at the given minute and stoppage minute.
+func at(minute, stoppage uint16) matchTime {
+	t, err := newMatchTime(minute, stoppage)
+	if err != nil {
+		log.WithError(err).Fatal("Invalid match time")
+	}
+	return t
+}
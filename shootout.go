@@ -0,0 +1,102 @@
+package main
+
+// shootoutRounds is how many kicks each lineup takes before the shootout
+// goes to sudden death.
+const shootoutRounds = 5
+
+// penaltyKick is a kick of a penalty shootout. Order is the position of the
+// kick in the shootout, starting at 1.
+type penaltyKick struct {
+	Order        int        `json:"order"`
+	ActionID     int64      `json:"action_id"`
+	LineupID     int64      `json:"lineup_id"`
+	Side         string     `json:"side"`
+	KickerID     int64      `json:"kicker_id"`
+	GoalkeeperID int64      `json:"goalkeeper_id"`
+	Outcome      actionType `json:"outcome"`
+}
+
+// shootout is the penalty shootout of a match. Its score is kept apart from
+// the score of the match.
+type shootout struct {
+	Kicks          []penaltyKick `json:"kicks"`
+	Score          score         `json:"score"`
+	WinnerLineupID *int64        `json:"winner_lineup_id,omitempty"`
+
+	homeKicks int
+	awayKicks int
+}
+
+// due reports whether the lineup is the one to take the next kick. Lineups
+// take turns, starting with the one that kicked first.
+func (so *shootout) due(m *match, lineupID int64) bool {
+	if len(so.Kicks) == 0 {
+		return true
+	}
+
+	if so.homeKicks == so.awayKicks {
+		return so.Kicks[0].LineupID == lineupID
+	}
+
+	if m.isHome(lineupID) {
+		return so.homeKicks < so.awayKicks
+	}
+	return so.awayKicks < so.homeKicks
+}
+
+// kick records a kick of the shootout and decides it if it is over.
+func (so *shootout) kick(m *match, a *action) {
+	k := penaltyKick{
+		Order:    len(so.Kicks) + 1,
+		ActionID: a.ActionID,
+		LineupID: a.LineupID,
+		KickerID: a.PlayerID,
+		Outcome:  a.Type,
+	}
+	if a.GoalkeeperID != nil {
+		k.GoalkeeperID = *a.GoalkeeperID
+	}
+
+	home := m.isHome(a.LineupID)
+	if home {
+		k.Side = sideHome
+		so.homeKicks++
+	} else {
+		k.Side = sideAway
+		so.awayKicks++
+	}
+
+	if a.Type == ACTION_PENALTY_SCORED {
+		if home {
+			so.Score.Home++
+		} else {
+			so.Score.Away++
+		}
+	}
+
+	so.Kicks = append(so.Kicks, k)
+	so.decide(m)
+}
+
+// decide sets the winner once the other lineup can no longer catch up
+// within the regular rounds or, in sudden death, once a round ends with one
+// lineup ahead.
+func (so *shootout) decide(m *match) {
+	home, away := so.Score.Home, so.Score.Away
+
+	var homeWins, awayWins bool
+	if so.homeKicks <= shootoutRounds && so.awayKicks <= shootoutRounds {
+		homeWins = home > away+shootoutRounds-so.awayKicks
+		awayWins = away > home+shootoutRounds-so.homeKicks
+	} else if so.homeKicks == so.awayKicks {
+		homeWins = home > away
+		awayWins = away > home
+	}
+
+	switch {
+	case homeWins:
+		so.WinnerLineupID = m.HomeLineupID
+	case awayWins:
+		so.WinnerLineupID = m.AwayLineupID
+	}
+}
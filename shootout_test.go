@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShootout(t *testing.T) {
+	m := &match{HomeLineupID: int64Ptr(1), AwayLineupID: int64Ptr(2)}
+
+	const (
+		S = ACTION_PENALTY_SCORED
+		X = ACTION_PENALTY_SAVED
+		M = ACTION_PENALTY_MISSED
+	)
+
+	for _, tc := range []struct {
+		Name           string
+		Kicks          []actionType
+		ExpectedScore  score
+		ExpectedWinner *int64
+	}{
+		{
+			Name:          "Undecided after the first round",
+			Kicks:         []actionType{S, X},
+			ExpectedScore: score{Home: 1, Away: 0},
+		},
+		{
+			Name:           "Away side cannot catch up",
+			Kicks:          []actionType{S, X, S, M, S, X},
+			ExpectedScore:  score{Home: 3, Away: 0},
+			ExpectedWinner: int64Ptr(1),
+		},
+		{
+			Name:           "Home side misses with kicks left",
+			Kicks:          []actionType{X, S, M, S, S, S, X},
+			ExpectedScore:  score{Home: 1, Away: 3},
+			ExpectedWinner: int64Ptr(2),
+		},
+		{
+			Name:          "Level after the regular rounds",
+			Kicks:         []actionType{S, S, S, S, S, S, S, S, X, X},
+			ExpectedScore: score{Home: 4, Away: 4},
+		},
+		{
+			Name:          "Sudden death waits for the round to end",
+			Kicks:         []actionType{S, S, S, S, S, S, S, S, X, X, S},
+			ExpectedScore: score{Home: 5, Away: 4},
+		},
+		{
+			Name:           "Sudden death decided",
+			Kicks:          []actionType{S, S, S, S, S, S, S, S, X, X, S, M},
+			ExpectedScore:  score{Home: 5, Away: 4},
+			ExpectedWinner: int64Ptr(1),
+		},
+		{
+			Name:           "Sudden death decided for the away side",
+			Kicks:          []actionType{S, S, S, S, S, S, S, S, S, S, S, S, X, S},
+			ExpectedScore:  score{Home: 6, Away: 7},
+			ExpectedWinner: int64Ptr(2),
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var so shootout
+			for i, outcome := range tc.Kicks {
+				lineupID := int64(1 + i%2)
+				r.True(so.due(m, lineupID))
+				r.Nil(so.WinnerLineupID)
+
+				so.kick(m, &action{
+					ActionID: int64(i + 1),
+					LineupID: lineupID,
+					Type:     outcome,
+				})
+			}
+
+			r.Equal(tc.ExpectedScore, so.Score)
+			r.Equal(tc.ExpectedWinner, so.WinnerLineupID)
+			r.Len(so.Kicks, len(tc.Kicks))
+			r.Equal(len(tc.Kicks), so.Kicks[len(so.Kicks)-1].Order)
+		})
+	}
+}
+
+func TestShootoutTurns(t *testing.T) {
+	r := require.New(t)
+
+	m := &match{HomeLineupID: int64Ptr(1), AwayLineupID: int64Ptr(2)}
+
+	var so shootout
+	r.True(so.due(m, 1))
+	r.True(so.due(m, 2))
+
+	// The away side kicks first, so it opens every round.
+	so.kick(m, &action{LineupID: 2, Type: ACTION_PENALTY_SCORED})
+	r.True(so.due(m, 1))
+	r.False(so.due(m, 2))
+
+	so.kick(m, &action{LineupID: 1, Type: ACTION_PENALTY_SCORED})
+	r.False(so.due(m, 1))
+	r.True(so.due(m, 2))
+	r.Equal(sideAway, so.Kicks[0].Side)
+	r.Equal(sideHome, so.Kicks[1].Side)
+}
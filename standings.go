@@ -0,0 +1,251 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type tiebreaker uint16
+
+func (t tiebreaker) String() string {
+	s, ok := tiebreaker_name[int(t)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(t))
+}
+
+func (t tiebreaker) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *tiebreaker) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := tiebreaker_value[s]; ok {
+		*t = tiebreaker(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
+const (
+	TIEBREAKER_INVALID tiebreaker = iota
+	TIEBREAKER_HEAD_TO_HEAD
+	TIEBREAKER_GOAL_DIFFERENCE
+	TIEBREAKER_GOALS_SCORED
+)
+
+var tiebreaker_name = map[int]string{
+	0: "TIEBREAKER_INVALID",
+	1: "TIEBREAKER_HEAD_TO_HEAD",
+	2: "TIEBREAKER_GOAL_DIFFERENCE",
+	3: "TIEBREAKER_GOALS_SCORED",
+}
+
+var tiebreaker_value = map[string]int{
+	"TIEBREAKER_INVALID":         0,
+	"TIEBREAKER_HEAD_TO_HEAD":    1,
+	"TIEBREAKER_GOAL_DIFFERENCE": 2,
+	"TIEBREAKER_GOALS_SCORED":    3,
+}
+
+// tiebreakers are the criteria that rank teams level on points, in the
+// order they are applied. They are stored as JSON.
+type tiebreakers []tiebreaker
+
+var defaultTiebreakers = tiebreakers{
+	TIEBREAKER_GOAL_DIFFERENCE,
+	TIEBREAKER_GOALS_SCORED,
+	TIEBREAKER_HEAD_TO_HEAD,
+}
+
+// valid reports whether every criterion is known and applied only once.
+func (t tiebreakers) valid() bool {
+	seen := map[tiebreaker]bool{}
+	for _, tb := range t {
+		if _, ok := tiebreaker_name[int(tb)]; !ok || tb == TIEBREAKER_INVALID || seen[tb] {
+			return false
+		}
+		seen[tb] = true
+	}
+	return true
+}
+
+func (t tiebreakers) Value() (driver.Value, error) {
+	if len(t) == 0 {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]tiebreaker(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (t *tiebreakers) Scan(src interface{}) error {
+	b, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into tiebreakers", src)
+	}
+	return json.Unmarshal(b, (*[]tiebreaker)(t))
+}
+
+const (
+	pointsForWin  = 3
+	pointsForDraw = 1
+)
+
+// standing is the record of a team in a season table. Teams level on every
+// criterion share their position.
+type standing struct {
+	Position       int    `json:"position"`
+	TeamID         int64  `json:"team_id"`
+	Name           string `json:"name"`
+	Played         int    `json:"played"`
+	Won            int    `json:"won"`
+	Drawn          int    `json:"drawn"`
+	Lost           int    `json:"lost"`
+	GoalsFor       int    `json:"goals_for"`
+	GoalsAgainst   int    `json:"goals_against"`
+	GoalDifference int    `json:"goal_difference"`
+	Points         int    `json:"points"`
+}
+
+// matchResult is the final score of a match between two teams.
+type matchResult struct {
+	HomeTeamID int64
+	AwayTeamID int64
+	Score      score
+}
+
+// tally adds up the results played between teams of the table.
+func tally(table map[int64]*standing, results []matchResult) {
+	for _, res := range results {
+		home, away := table[res.HomeTeamID], table[res.AwayTeamID]
+		if home == nil || away == nil {
+			continue
+		}
+
+		home.record(res.Score.Home, res.Score.Away)
+		away.record(res.Score.Away, res.Score.Home)
+	}
+}
+
+func (st *standing) record(scored, conceded int) {
+	st.Played++
+	st.GoalsFor += scored
+	st.GoalsAgainst += conceded
+	st.GoalDifference = st.GoalsFor - st.GoalsAgainst
+
+	switch {
+	case scored > conceded:
+		st.Won++
+		st.Points += pointsForWin
+	case scored == conceded:
+		st.Drawn++
+		st.Points += pointsForDraw
+	default:
+		st.Lost++
+	}
+}
+
+// computeStandings ranks the teams by points from the results of their
+// matches, breaking ties with the given criteria.
+func computeStandings(teams []team, results []matchResult, criteria tiebreakers) []standing {
+	table := map[int64]*standing{}
+	rows := make([]*standing, len(teams))
+	for i, t := range teams {
+		rows[i] = &standing{TeamID: t.TeamID, Name: t.Name}
+		table[t.TeamID] = rows[i]
+	}
+
+	tally(table, results)
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Points != rows[j].Points {
+			return rows[i].Points > rows[j].Points
+		}
+		return rows[i].TeamID < rows[j].TeamID
+	})
+
+	var tied [][]*standing
+	for _, group := range split(rows, func(st *standing) []int { return []int{st.Points} }) {
+		tied = append(tied, breakTies(group, results, criteria)...)
+	}
+
+	standings := make([]standing, 0, len(rows))
+	for _, group := range tied {
+		position := len(standings) + 1
+		for _, st := range group {
+			st.Position = position
+			standings = append(standings, *st)
+		}
+	}
+
+	return standings
+}
+
+// breakTies orders a group of teams level on points applying the criteria
+// one after the other to the teams still level. It returns the teams grouped
+// by those level on every criterion.
+func breakTies(group []*standing, results []matchResult, criteria tiebreakers) [][]*standing {
+	if len(group) < 2 || len(criteria) == 0 {
+		return [][]*standing{group}
+	}
+
+	var key func(*standing) []int
+	switch criteria[0] {
+	case TIEBREAKER_HEAD_TO_HEAD:
+		// Rank the teams on a table of the matches played among them.
+		mini := map[int64]*standing{}
+		for _, st := range group {
+			mini[st.TeamID] = &standing{TeamID: st.TeamID}
+		}
+		tally(mini, results)
+
+		key = func(st *standing) []int {
+			return []int{mini[st.TeamID].Points, mini[st.TeamID].GoalDifference}
+		}
+	case TIEBREAKER_GOAL_DIFFERENCE:
+		key = func(st *standing) []int { return []int{st.GoalDifference} }
+	case TIEBREAKER_GOALS_SCORED:
+		key = func(st *standing) []int { return []int{st.GoalsFor} }
+	default:
+		return breakTies(group, results, criteria[1:])
+	}
+
+	sort.SliceStable(group, func(i, j int) bool {
+		return greater(key(group[i]), key(group[j]))
+	})
+
+	var tied [][]*standing
+	for _, sub := range split(group, key) {
+		tied = append(tied, breakTies(sub, results, criteria[1:])...)
+	}
+	return tied
+}
+
+// split splits sorted teams into runs of teams with the same key.
+func split(rows []*standing, key func(*standing) []int) [][]*standing {
+	var groups [][]*standing
+	for i, st := range rows {
+		if i == 0 || greater(key(rows[i-1]), key(st)) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], st)
+	}
+	return groups
+}
+
+// greater compares keys lexicographically.
+func greater(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+)
+
+func standingsKey(seasonID int64) string {
+	return fmt.Sprintf("/seasons/%d/standings", seasonID)
+}
+
+// purgeStandings drops the cached standings of the season after a change to
+// its results, teams or tiebreakers. Matches outside of a season have none.
+func (s *server) purgeStandings(seasonID *int64) {
+	if s.config.disableCache || seasonID == nil {
+		return
+	}
+
+	err := s.redis.Del(standingsKey(*seasonID)).Err()
+	if err != nil {
+		log.WithError(err).WithField("season_id", *seasonID).Error("Failed to purge season standings")
+	}
+}
+
+// getStandings returns the table of the season from the scores of its
+// finished matches.
+func (s *server) getStandings(c echo.Context) error {
+	found := new(season)
+
+	err := s.db.Collection(seasonsTable).Find("season_id", getSeasonID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("season_id", getSeasonID(c)).Debug("season not found")
+		return echo.NewHTTPError(http.StatusNotFound, errSeasonNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve season from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	teams, err := seasonTeams(s.db, found.SeasonID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list season teams from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var matches []match
+
+	err = s.db.Collection(matchesTable).Find("season_id", found.SeasonID).
+		And("status", MATCH_STATUS_FINISHED).OrderBy("match_id").All(&matches)
+	if err != nil {
+		log.WithError(err).Error("Failed to list season matches from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var results []matchResult
+	for i := range matches {
+		m := &matches[i]
+		if m.HomeTeamID == nil || m.AwayTeamID == nil {
+			continue
+		}
+
+		sc, err := s.matchScore(m)
+		if err != nil {
+			log.WithError(err).Error("Failed to compute match score")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		results = append(results, matchResult{
+			HomeTeamID: *m.HomeTeamID,
+			AwayTeamID: *m.AwayTeamID,
+			Score:      *sc,
+		})
+	}
+
+	criteria := found.Tiebreakers
+	if len(criteria) == 0 {
+		criteria = defaultTiebreakers
+	}
+
+	standings := computeStandings(teams, results, criteria)
+
+	return c.JSON(http.StatusOK, &standings)
+}
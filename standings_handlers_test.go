@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeasonStandings(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(competitionsTable).Insert(&competition{Name: "Liga"})
+	r.Nil(err)
+
+	_, err = s.db.Collection(seasonsTable).Insert(&season{CompetitionID: 1, Name: "2020/21", Rounds: 2})
+	r.Nil(err)
+
+	for _, tm := range []team{
+		{TeamID: int64(1), Name: "Foo FC"},
+		{TeamID: int64(2), Name: "Bar United"},
+		{TeamID: int64(3), Name: "Baz City"},
+	} {
+		_, err := s.db.Collection(teamsTable).Insert(&tm)
+		r.Nil(err)
+
+		_, err = s.db.Collection(seasonTeamsTable).Insert(&seasonTeam{SeasonID: 1, TeamID: tm.TeamID})
+		r.Nil(err)
+
+		_, err = s.db.Collection(playersTable).Insert(&player{
+			PlayerID:    tm.TeamID,
+			TeamID:      int64Ptr(tm.TeamID),
+			DisplayName: tm.Name,
+			Number:      9,
+			Position:    POSITION_STRIKER,
+		})
+		r.Nil(err)
+	}
+
+	// Foo beat Bar and Bar beat Baz. Baz beat Foo in a match not finished
+	// yet.
+	for i, m := range []match{
+		{HomeTeamID: int64Ptr(1), AwayTeamID: int64Ptr(2), Status: MATCH_STATUS_FINISHED},
+		{HomeTeamID: int64Ptr(2), AwayTeamID: int64Ptr(3), Status: MATCH_STATUS_FINISHED},
+		{HomeTeamID: int64Ptr(3), AwayTeamID: int64Ptr(1), Status: MATCH_STATUS_LIVE},
+	} {
+		home, away := int64(2*i+1), int64(2*i+2)
+
+		for _, l := range []lineup{
+			{LineupID: home, TeamID: m.HomeTeamID, Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true)},
+			{LineupID: away, TeamID: m.AwayTeamID, Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(false)},
+		} {
+			_, err := s.db.Collection(lineupsTable).Insert(&l)
+			r.Nil(err)
+
+			_, err = s.db.Collection(lineupPlayersTable).Insert(&lineupPlayer{
+				LineupID: l.LineupID,
+				PlayerID: *l.TeamID,
+				TeamID:   l.TeamID,
+			})
+			r.Nil(err)
+		}
+
+		m.MatchID = int64(i + 1)
+		m.SeasonID = int64Ptr(1)
+		m.HomeLineupID = int64Ptr(home)
+		m.AwayLineupID = int64Ptr(away)
+		_, err := s.db.Collection(matchesTable).Insert(&m)
+		r.Nil(err)
+	}
+
+	for _, a := range []action{
+		{MatchID: 1, LineupID: 1, PlayerID: 1, Type: ACTION_GOAL, Time: at(10, 0)},
+		{MatchID: 2, LineupID: 3, PlayerID: 2, Type: ACTION_GOAL, Time: at(20, 0)},
+		{MatchID: 2, LineupID: 3, PlayerID: 2, Type: ACTION_GOAL, Time: at(30, 0)},
+		{MatchID: 3, LineupID: 5, PlayerID: 3, Type: ACTION_GOAL, Time: at(40, 0)},
+	} {
+		_, err := s.db.Collection(actionsTable).Insert(&a)
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:               "Standings of an unknown season",
+			Method:             "GET",
+			Target:             "/seasons/2/standings",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"season not found"}`,
+		},
+		{
+			Name:               "Level teams ranked by goals scored",
+			Method:             "GET",
+			Target:             "/seasons/1/standings",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"position":1,"team_id":2,"name":"Bar United","played":2,"won":1,"drawn":0,"lost":1,"goals_for":2,"goals_against":1,"goal_difference":1,"points":3},{"position":2,"team_id":1,"name":"Foo FC","played":1,"won":1,"drawn":0,"lost":0,"goals_for":1,"goals_against":0,"goal_difference":1,"points":3},{"position":3,"team_id":3,"name":"Baz City","played":1,"won":0,"drawn":0,"lost":1,"goals_for":0,"goals_against":2,"goal_difference":-2,"points":0}]`,
+		},
+		{
+			Name:   "Invalid tiebreakers",
+			Method: "PUT",
+			Target: "/seasons/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"tiebreakers": []string{"TIEBREAKER_GOALS_SCORED", "TIEBREAKER_GOALS_SCORED"},
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`tiebreakers`" + ` value"}`,
+		},
+		{
+			Name:   "Unknown tiebreaker",
+			Method: "PUT",
+			Target: "/seasons/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"tiebreakers": []string{"TIEBREAKER_FOO"},
+			},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:   "Rank level teams head-to-head",
+			Method: "PUT",
+			Target: "/seasons/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: season{
+				Tiebreakers: tiebreakers{TIEBREAKER_HEAD_TO_HEAD},
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get season with tiebreakers",
+			Method:             "GET",
+			Target:             "/seasons/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"season_id":1,"competition_id":1,"name":"2020/21","rounds":2,"tiebreakers":["TIEBREAKER_HEAD_TO_HEAD"]}`,
+		},
+		{
+			Name:               "Level teams ranked head-to-head",
+			Method:             "GET",
+			Target:             "/seasons/1/standings",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"position":1,"team_id":1,"name":"Foo FC","played":1,"won":1,"drawn":0,"lost":0,"goals_for":1,"goals_against":0,"goal_difference":1,"points":3},{"position":2,"team_id":2,"name":"Bar United","played":2,"won":1,"drawn":0,"lost":1,"goals_for":2,"goals_against":1,"goal_difference":1,"points":3},{"position":3,"team_id":3,"name":"Baz City","played":1,"won":0,"drawn":0,"lost":1,"goals_for":0,"goals_against":2,"goal_difference":-2,"points":0}]`,
+		},
+		{
+			Name:   "Annul the goal of Foo",
+			Method: "DELETE",
+			Target: "/matches/1/actions/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: map[string]interface{}{
+				"changed_by": "VAR",
+				"reason":     "Offside",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Standings after annulling a goal",
+			Method:             "GET",
+			Target:             "/seasons/1/standings",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"position":1,"team_id":2,"name":"Bar United","played":2,"won":1,"drawn":1,"lost":0,"goals_for":2,"goals_against":0,"goal_difference":2,"points":4},{"position":2,"team_id":1,"name":"Foo FC","played":1,"won":0,"drawn":1,"lost":0,"goals_for":0,"goals_against":0,"goal_difference":0,"points":1},{"position":3,"team_id":3,"name":"Baz City","played":1,"won":0,"drawn":0,"lost":1,"goals_for":0,"goals_against":2,"goal_difference":-2,"points":0}]`,
+		},
+		{
+			Name:   "Finish the match between Baz and Foo",
+			Method: "PUT",
+			Target: "/matches/3",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Standings after a match finishes",
+			Method:             "GET",
+			Target:             "/seasons/1/standings",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"position":1,"team_id":2,"name":"Bar United","played":2,"won":1,"drawn":1,"lost":0,"goals_for":2,"goals_against":0,"goal_difference":2,"points":4},{"position":2,"team_id":3,"name":"Baz City","played":2,"won":1,"drawn":0,"lost":1,"goals_for":1,"goals_against":2,"goal_difference":-1,"points":3},{"position":3,"team_id":1,"name":"Foo FC","played":2,"won":0,"drawn":1,"lost":1,"goals_for":0,"goals_against":1,"goal_difference":-1,"points":1}]`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+
+	// Changing a result purges the cached standings of the season.
+	s.config.disableCache = false
+	r.Nil(s.redis.Set(standingsKey(1), "{}", 0).Err())
+
+	body, err := json.Marshal(map[string]interface{}{"changed_by": "VAR", "reason": "Foul"})
+	r.Nil(err)
+	req := httptest.NewRequest("DELETE", "/matches/2/actions/2", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusOK, rec.Code)
+
+	n, err := s.redis.Exists(standingsKey(1)).Result()
+	r.Nil(err)
+	r.Equal(int64(0), n)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeStandings(t *testing.T) {
+	teams := []team{
+		{TeamID: 1, Name: "Foo FC"},
+		{TeamID: 2, Name: "Bar United"},
+		{TeamID: 3, Name: "Baz City"},
+		{TeamID: 4, Name: "Qux Athletic"},
+	}
+
+	result := func(home, away int64, homeGoals, awayGoals int) matchResult {
+		return matchResult{
+			HomeTeamID: home,
+			AwayTeamID: away,
+			Score:      score{Home: homeGoals, Away: awayGoals},
+		}
+	}
+
+	// Foo and Bar are level on points: Bar has the better goal difference
+	// but Foo won the match between them.
+	level := []matchResult{
+		result(1, 2, 1, 0),
+		result(2, 3, 4, 0),
+		result(1, 4, 0, 0),
+		result(4, 2, 1, 1),
+	}
+
+	// Foo and Bar drew between them and have the same goal difference, but
+	// Bar scored more goals.
+	drawn := []matchResult{
+		result(1, 2, 1, 1),
+		result(1, 3, 2, 0),
+		result(2, 3, 3, 1),
+	}
+
+	for _, tc := range []struct {
+		Name              string
+		Results           []matchResult
+		Criteria          tiebreakers
+		ExpectedTeams     []int64
+		ExpectedPositions []int
+	}{
+		{
+			Name:              "No matches played",
+			Criteria:          defaultTiebreakers,
+			ExpectedTeams:     []int64{1, 2, 3, 4},
+			ExpectedPositions: []int{1, 1, 1, 1},
+		},
+		{
+			Name:              "Goal difference first",
+			Results:           level,
+			Criteria:          tiebreakers{TIEBREAKER_GOAL_DIFFERENCE, TIEBREAKER_HEAD_TO_HEAD},
+			ExpectedTeams:     []int64{2, 1, 4, 3},
+			ExpectedPositions: []int{1, 2, 3, 4},
+		},
+		{
+			Name:              "Head-to-head first",
+			Results:           level,
+			Criteria:          tiebreakers{TIEBREAKER_HEAD_TO_HEAD, TIEBREAKER_GOAL_DIFFERENCE},
+			ExpectedTeams:     []int64{1, 2, 4, 3},
+			ExpectedPositions: []int{1, 2, 3, 4},
+		},
+		{
+			Name:              "Goals scored",
+			Results:           level,
+			Criteria:          tiebreakers{TIEBREAKER_GOALS_SCORED},
+			ExpectedTeams:     []int64{2, 1, 4, 3},
+			ExpectedPositions: []int{1, 2, 3, 4},
+		},
+		{
+			Name:              "Drawn head-to-head falls through to the next criterion",
+			Results:           drawn,
+			Criteria:          tiebreakers{TIEBREAKER_HEAD_TO_HEAD, TIEBREAKER_GOAL_DIFFERENCE, TIEBREAKER_GOALS_SCORED},
+			ExpectedTeams:     []int64{2, 1, 4, 3},
+			ExpectedPositions: []int{1, 2, 3, 4},
+		},
+		{
+			Name:              "Level on every criterion",
+			Results:           drawn,
+			Criteria:          tiebreakers{TIEBREAKER_HEAD_TO_HEAD, TIEBREAKER_GOAL_DIFFERENCE},
+			ExpectedTeams:     []int64{1, 2, 4, 3},
+			ExpectedPositions: []int{1, 1, 3, 4},
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			standings := computeStandings(teams, tc.Results, tc.Criteria)
+
+			var ids []int64
+			var positions []int
+			for _, st := range standings {
+				ids = append(ids, st.TeamID)
+				positions = append(positions, st.Position)
+			}
+			r.Equal(tc.ExpectedTeams, ids)
+			r.Equal(tc.ExpectedPositions, positions)
+		})
+	}
+}
+
+func TestComputeStandingsTotals(t *testing.T) {
+	r := require.New(t)
+
+	standings := computeStandings(
+		[]team{{TeamID: 1, Name: "Foo FC"}, {TeamID: 2, Name: "Bar United"}},
+		[]matchResult{
+			{HomeTeamID: 1, AwayTeamID: 2, Score: score{Home: 3, Away: 1}},
+			{HomeTeamID: 2, AwayTeamID: 1, Score: score{Home: 2, Away: 2}},
+			// Results against teams outside of the table do not count.
+			{HomeTeamID: 1, AwayTeamID: 3, Score: score{Home: 5, Away: 0}},
+		},
+		defaultTiebreakers,
+	)
+
+	r.Equal([]standing{
+		{Position: 1, TeamID: 1, Name: "Foo FC", Played: 2, Won: 1, Drawn: 1, GoalsFor: 5, GoalsAgainst: 3, GoalDifference: 2, Points: 4},
+		{Position: 2, TeamID: 2, Name: "Bar United", Played: 2, Drawn: 1, Lost: 1, GoalsFor: 3, GoalsAgainst: 5, GoalDifference: -2, Points: 1},
+	}, standings)
+}
+
+func TestTiebreakersValid(t *testing.T) {
+	r := require.New(t)
+
+	r.True(tiebreakers{}.valid())
+	r.True(defaultTiebreakers.valid())
+	r.False(tiebreakers{TIEBREAKER_INVALID}.valid())
+	r.False(tiebreakers{tiebreaker(9)}.valid())
+	r.False(tiebreakers{TIEBREAKER_GOALS_SCORED, TIEBREAKER_GOALS_SCORED}.valid())
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/go-redis/redis"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	eventAction = "action"
+	eventScore  = "score"
+)
+
+// streamMaxLen is roughly how many events of each match are kept for
+// reconnecting clients to resume from.
+const streamMaxLen = 1000
+
+// streamKey returns the redis stream the events of the match are published
+// to. Every server instance reads from it, so clients get the events no
+// matter which instance recorded them.
+func streamKey(matchID int64) string {
+	return fmt.Sprintf("matches:%d:events", matchID)
+}
+
+// publish appends an event to the stream of the match. Failing to publish
+// does not undo the change that produced the event, so errors are only
+// logged.
+func (s *server) publish(matchID int64, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.WithError(err).Error("Failed to encode match event")
+		return
+	}
+
+	err = s.redis.XAdd(&redis.XAddArgs{
+		Stream:       streamKey(matchID),
+		MaxLenApprox: streamMaxLen,
+		Values: map[string]interface{}{
+			"event": event,
+			"data":  data,
+		},
+	}).Err()
+	if err != nil {
+		log.WithError(err).WithField("match_id", matchID).Error("Failed to publish match event")
+	}
+}
+
+// publishScore publishes the current score of the match.
+func (s *server) publishScore(m *match) {
+	sc, err := s.matchScore(m)
+	if err != nil {
+		log.WithError(err).Error("Failed to compute match score")
+		return
+	}
+	s.publish(m.MatchID, eventScore, sc)
+}
+
+// validEventID reports whether the string is a redis stream ID, the IDs
+// events are sent with.
+func validEventID(id string) bool {
+	parts := strings.SplitN(id, "-", 2)
+	for _, p := range parts {
+		if _, err := strconv.ParseUint(p, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// lastEventID returns the ID of the last event published for the match, so
+// a new client only gets the events that come after it.
+func (s *server) lastEventID(matchID int64) (string, error) {
+	msgs, err := s.redis.XRevRangeN(streamKey(matchID), "+", "-", 1).Result()
+	if err != nil {
+		return "", err
+	}
+	if len(msgs) == 0 {
+		return "0-0", nil
+	}
+	return msgs[0].ID, nil
+}
+
+func (s *server) getMatchStream(c echo.Context) error {
+	m, err := s.findMatch(getMatchID(c))
+	if err == errMatchNotFound {
+		log.WithField("match_id", getMatchID(c)).Debug("match not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve match from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	last := c.Request().Header.Get("Last-Event-ID")
+	if last == "" {
+		last, err = s.lastEventID(m.MatchID)
+		if err != nil {
+			log.WithError(err).Error("Failed to read match events")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+	} else if !validEventID(last) {
+		log.WithField("last_event_id", last).Debug("Invalid `Last-Event-ID`")
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid `Last-Event-ID`")
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	done := c.Request().Context().Done()
+	key := streamKey(m.MatchID)
+
+	for {
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+
+		streams, err := s.streams.XRead(&redis.XReadArgs{
+			Streams: []string{key, last},
+			Block:   s.config.streamHeartbeat,
+		}).Result()
+		if err == redis.Nil {
+			// Nothing happened, let the client know the stream is alive.
+			if _, err := fmt.Fprint(res, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+			continue
+		}
+		if err != nil {
+			log.WithError(err).Error("Failed to read match events")
+			return nil
+		}
+
+		for _, msg := range streams[0].Messages {
+			_, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n",
+				msg.ID, msg.Values["event"], msg.Values["data"])
+			if err != nil {
+				return nil
+			}
+			last = msg.ID
+		}
+		res.Flush()
+	}
+}
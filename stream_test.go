@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidEventID(t *testing.T) {
+	for id, valid := range map[string]bool{
+		"0":               true,
+		"0-0":             true,
+		"1559412000000-3": true,
+		"":                false,
+		"foo":             false,
+		"1-":              false,
+		"-1":              false,
+		"1-2-3":           false,
+	} {
+		require.Equal(t, valid, validEventID(id), id)
+	}
+}
+
+func TestMatchStream(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	s.config.streamHeartbeat = 50 * time.Millisecond
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(playersTable).Insert(&player{
+		PlayerID: int64(1), DisplayName: "Foo", Number: 9, Position: POSITION_STRIKER,
+	})
+	r.Nil(err)
+
+	_, err = s.db.Collection(lineupsTable).Insert(&lineup{
+		LineupID: int64(1), Formation: FORMATION_FOUR_FOUR_TWO, IsLocal: boolPtr(true),
+	})
+	r.Nil(err)
+
+	_, err = s.db.Collection(lineupPlayersTable).Insert(&lineupPlayer{
+		LineupID: int64(1), PlayerID: int64(1),
+	})
+	r.Nil(err)
+
+	_, err = s.db.Collection(matchesTable).Insert(&match{
+		MatchID:      int64(1),
+		HomeLineupID: int64Ptr(1),
+		Status:       MATCH_STATUS_LIVE,
+	})
+	r.Nil(err)
+
+	kickoff := time.Date(2019, time.June, 1, 18, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return kickoff }
+
+	for _, a := range []action{
+		{PlayerID: int64(1), Type: ACTION_CARD_YELLOW, Time: at(10, 0)},
+		{PlayerID: int64(1), Type: ACTION_GOAL, Time: at(12, 0)},
+	} {
+		body, err := json.Marshal(&a)
+		r.Nil(err)
+
+		req := httptest.NewRequest("POST", "/matches/1/actions", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		s.web.ServeHTTP(rec, req)
+		r.Equal(http.StatusOK, rec.Code)
+	}
+
+	stream := func(lastEventID string) (int, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		req := httptest.NewRequest("GET", "/matches/1/stream", nil).WithContext(ctx)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		rec := httptest.NewRecorder()
+		s.web.ServeHTTP(rec, req)
+
+		data, err := ioutil.ReadAll(rec.Result().Body)
+		r.Nil(err)
+		return rec.Code, string(data)
+	}
+
+	events := regexp.MustCompile(`id: (\S+)\nevent: (\S+)\ndata: (.*)\n\n`)
+
+	code, body := stream("0")
+	r.Equal(http.StatusOK, code)
+
+	found := events.FindAllStringSubmatch(body, -1)
+	r.Len(found, 3)
+	r.Equal(eventAction, found[0][2])
+	r.Equal(`{"action_id":1,"match_id":1,"lineup_id":1,"player_id":1,"action":"ACTION_CARD_YELLOW","time":"10'","recorded_at":"2019-06-01T18:00:00Z"}`, found[0][3])
+	r.Equal(eventAction, found[1][2])
+	r.Equal(`{"action_id":2,"match_id":1,"lineup_id":1,"player_id":1,"action":"ACTION_GOAL","time":"12'","recorded_at":"2019-06-01T18:00:00Z"}`, found[1][3])
+	r.Equal(eventScore, found[2][2])
+	r.Equal(`{"home":1,"away":0}`, found[2][3])
+
+	// Resuming from the first event sends the ones after it only.
+	code, body = stream(found[0][1])
+	r.Equal(http.StatusOK, code)
+
+	resumed := events.FindAllStringSubmatch(body, -1)
+	r.Len(resumed, 2)
+	r.Equal(found[1:], resumed)
+
+	// New clients only get the events published from then on.
+	code, body = stream("")
+	r.Equal(http.StatusOK, code)
+	r.Empty(events.FindAllStringSubmatch(body, -1))
+	r.Contains(body, ": heartbeat\n\n")
+
+	code, _ = stream("foo")
+	r.Equal(http.StatusBadRequest, code)
+
+	req := httptest.NewRequest("GET", "/matches/2/stream", nil)
+	rec := httptest.NewRecorder()
+	s.web.ServeHTTP(rec, req)
+	r.Equal(http.StatusNotFound, rec.Code)
+}
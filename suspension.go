@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+type suspensionReason uint16
+
+func (r suspensionReason) String() string {
+	s, ok := suspensionReason_name[int(r)]
+	if ok {
+		return s
+	}
+	return strconv.Itoa(int(r))
+}
+
+func (r suspensionReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *suspensionReason) UnmarshalText(b []byte) error {
+	s := string(b)
+	if i, ok := suspensionReason_value[s]; ok {
+		*r = suspensionReason(i)
+		return nil
+	}
+	return fmt.Errorf("Could not parse %s", b)
+}
+
+const (
+	SUSPENSION_REASON_INVALID suspensionReason = iota
+	SUSPENSION_REASON_RED_CARD
+	SUSPENSION_REASON_YELLOW_CARDS
+)
+
+var suspensionReason_name = map[int]string{
+	0: "SUSPENSION_REASON_INVALID",
+	1: "SUSPENSION_REASON_RED_CARD",
+	2: "SUSPENSION_REASON_YELLOW_CARDS",
+}
+
+var suspensionReason_value = map[string]int{
+	"SUSPENSION_REASON_INVALID":      0,
+	"SUSPENSION_REASON_RED_CARD":     1,
+	"SUSPENSION_REASON_YELLOW_CARDS": 2,
+}
+
+// suspensionPolicy sets how many matches players are suspended for after a
+// red card, and after every yellowLimit yellow cards in a competition.
+type suspensionPolicy struct {
+	redCard     int
+	yellowLimit int
+	yellowCards int
+}
+
+// suspension bans a player from the competition for a number of matches
+// after the one they were booked in, MatchID. It is served by the following
+// matches of the team they were playing for, Remaining is how many of them
+// are left.
+type suspension struct {
+	PlayerID      int64            `json:"player_id"`
+	TeamID        int64            `json:"team_id"`
+	CompetitionID int64            `json:"competition_id"`
+	Reason        suspensionReason `json:"reason"`
+	MatchID       int64            `json:"match_id"`
+	Matches       int              `json:"matches"`
+	Remaining     int              `json:"remaining"`
+}
+
+// suspendedError is returned when a suspended player is picked for a match of
+// the competition they are suspended in.
+type suspendedError struct {
+	Suspension *suspension
+}
+
+func (e *suspendedError) Error() string {
+	return fmt.Sprintf("player is suspended in the competition for %d more match(es) (%s in match %d)",
+		e.Suspension.Remaining, e.Suspension.Reason, e.Suspension.MatchID)
+}
+
+// computeSuspensions goes through the finished matches of a competition in
+// the order they were played, given the bookings players got in them. Each
+// suspension is served after the previous one of the player. Yellow cards of
+// a player sent off in the same match do not count towards the limit, the
+// dismissal carries its own suspension.
+func computeSuspensions(policy suspensionPolicy, competitionID int64, matches []match, bookings []playerMatchStats) []suspension {
+	byMatch := map[int64][]playerMatchStats{}
+	for _, b := range bookings {
+		byMatch[b.MatchID] = append(byMatch[b.MatchID], b)
+	}
+
+	var (
+		suspensions []suspension
+		// yellows are the yellow cards of each player in the competition.
+		yellows = map[int64]int{}
+	)
+
+	for i := range matches {
+		m := &matches[i]
+
+		// The match serves the first pending suspension of every player of
+		// the teams playing it.
+		serving := map[int64]bool{}
+		for j := range suspensions {
+			sp := &suspensions[j]
+			if sp.Remaining == 0 || serving[sp.PlayerID] {
+				continue
+			}
+			if (m.HomeTeamID != nil && *m.HomeTeamID == sp.TeamID) || (m.AwayTeamID != nil && *m.AwayTeamID == sp.TeamID) {
+				sp.Remaining--
+				serving[sp.PlayerID] = true
+			}
+		}
+
+		for _, b := range byMatch[m.MatchID] {
+			teamID := m.teamOf(b.LineupID)
+			if teamID == nil {
+				continue
+			}
+
+			booked := suspension{
+				PlayerID:      b.PlayerID,
+				TeamID:        *teamID,
+				CompetitionID: competitionID,
+				MatchID:       m.MatchID,
+			}
+
+			if b.RedCards > 0 {
+				if policy.redCard > 0 {
+					booked.Reason = SUSPENSION_REASON_RED_CARD
+					booked.Matches = policy.redCard
+					booked.Remaining = policy.redCard
+					suspensions = append(suspensions, booked)
+				}
+				continue
+			}
+
+			if policy.yellowLimit <= 0 || policy.yellowCards <= 0 {
+				continue
+			}
+
+			before := yellows[b.PlayerID]
+			yellows[b.PlayerID] += b.YellowCards
+			if yellows[b.PlayerID]/policy.yellowLimit > before/policy.yellowLimit {
+				booked.Reason = SUSPENSION_REASON_YELLOW_CARDS
+				booked.Matches = policy.yellowCards
+				booked.Remaining = policy.yellowCards
+				suspensions = append(suspensions, booked)
+			}
+		}
+	}
+
+	return suspensions
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+func (s *server) suspensionPolicy() suspensionPolicy {
+	return suspensionPolicy{
+		redCard:     s.config.redCardSuspension,
+		yellowLimit: s.config.yellowCardLimit,
+		yellowCards: s.config.yellowCardSuspension,
+	}
+}
+
+// loadSuspensions computes the suspensions of a competition from the bookings
+// of its finished matches, served ones included.
+func loadSuspensions(sess sqlbuilder.SQLBuilder, competitionID int64, policy suspensionPolicy) ([]suspension, error) {
+	var matches []match
+
+	err := sess.Select("m.*").From(fmt.Sprintf("%s AS m", matchesTable)).
+		Join(fmt.Sprintf("%s AS s", seasonsTable)).On("s.season_id = m.season_id").
+		Where("s.competition_id", competitionID).And("m.status", MATCH_STATUS_FINISHED).
+		All(&matches)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	// Matches are served in the order they were played, those without a
+	// kickoff go last.
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i].Kickoff, matches[j].Kickoff
+		switch {
+		case a != nil && b != nil && !a.Equal(*b):
+			return a.Before(*b)
+		case (a == nil) != (b == nil):
+			return a != nil
+		}
+		return matches[i].MatchID < matches[j].MatchID
+	})
+
+	ids := make([]int64, 0, len(matches))
+	for i := range matches {
+		ids = append(ids, matches[i].MatchID)
+	}
+
+	var bookings []playerMatchStats
+
+	err = sess.SelectFrom(playerMatchStatsTable).Where(db.Cond{"match_id": db.In(ids)}).
+		And(db.Raw("(yellow_cards > 0 OR red_cards > 0)")).
+		OrderBy("match_id", "player_id").All(&bookings)
+	if err != nil {
+		return nil, err
+	}
+
+	return computeSuspensions(policy, competitionID, matches, bookings), nil
+}
+
+// matchSuspensions returns the suspensions still to be served by players in
+// the competition of the match, by player.
+func (s *server) matchSuspensions(sess sqlbuilder.SQLBuilder, m *match) (map[int64]*suspension, error) {
+	if m.SeasonID == nil {
+		return nil, nil
+	}
+
+	var found season
+
+	err := sess.SelectFrom(seasonsTable).Where("season_id", *m.SeasonID).One(&found)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := loadSuspensions(sess, found.CompetitionID, s.suspensionPolicy())
+	if err != nil {
+		return nil, err
+	}
+
+	active := map[int64]*suspension{}
+	for i := range all {
+		if all[i].Remaining > 0 && active[all[i].PlayerID] == nil {
+			active[all[i].PlayerID] = &all[i]
+		}
+	}
+
+	return active, nil
+}
+
+// listTeamSuspensions returns the suspensions still to be served by players
+// of the team, in every competition the team takes part in.
+func (s *server) listTeamSuspensions(c echo.Context) error {
+	found := new(team)
+
+	err := s.db.Collection(teamsTable).Find("team_id", getTeamID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("team_id", getTeamID(c)).Debug("team not found")
+		return echo.NewHTTPError(http.StatusNotFound, errTeamNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve team from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	var competitions []struct {
+		CompetitionID int64 `db:"competition_id"`
+	}
+
+	err = s.db.Select(db.Raw("DISTINCT s.competition_id")).From(fmt.Sprintf("%s AS s", seasonsTable)).
+		Join(fmt.Sprintf("%s AS st", seasonTeamsTable)).On("st.season_id = s.season_id").
+		Where("st.team_id", found.TeamID).OrderBy("s.competition_id").All(&competitions)
+	if err != nil {
+		log.WithError(err).Error("Failed to list team competitions from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	suspensions := []suspension{}
+	for _, comp := range competitions {
+		all, err := loadSuspensions(s.db, comp.CompetitionID, s.suspensionPolicy())
+		if err != nil {
+			log.WithError(err).Error("Failed to compute suspensions")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		for i := range all {
+			if all[i].TeamID == found.TeamID && all[i].Remaining > 0 {
+				suspensions = append(suspensions, all[i])
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, suspensions)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamSuspensions(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	r := require.New(t)
+
+	_, err := s.db.Collection(competitionsTable).Insert(&competition{CompetitionID: int64(1), Name: "Liga"})
+	r.Nil(err)
+
+	_, err = s.db.Collection(seasonsTable).Insert(&season{SeasonID: int64(1), CompetitionID: int64(1), Name: "2020/21", Rounds: 2})
+	r.Nil(err)
+
+	for _, tm := range []team{
+		{TeamID: int64(1), Name: "Home"},
+		{TeamID: int64(2), Name: "Away"},
+		{TeamID: int64(3), Name: "Idle"},
+	} {
+		_, err := s.db.Collection(teamsTable).Insert(&tm)
+		r.Nil(err)
+	}
+
+	for _, st := range []seasonTeam{
+		{SeasonID: int64(1), TeamID: int64(1)},
+		{SeasonID: int64(1), TeamID: int64(2)},
+	} {
+		_, err := s.db.Collection(seasonTeamsTable).Insert(&st)
+		r.Nil(err)
+	}
+
+	for _, p := range []player{
+		{PlayerID: int64(1), TeamID: int64Ptr(1), DisplayName: "A", Number: 4, Position: POSITION_DEFENDER},
+		{PlayerID: int64(2), TeamID: int64Ptr(2), DisplayName: "B", Number: 9, Position: POSITION_STRIKER},
+		{PlayerID: int64(3), TeamID: int64Ptr(1), DisplayName: "C", Number: 5, Position: POSITION_DEFENDER},
+	} {
+		_, err := s.db.Collection(playersTable).Insert(&p)
+		r.Nil(err)
+	}
+
+	for _, l := range []lineup{
+		{LineupID: int64(1), TeamID: int64Ptr(1), IsLocal: boolPtr(true)},
+		{LineupID: int64(2), TeamID: int64Ptr(2), IsLocal: boolPtr(false)},
+		{LineupID: int64(3), TeamID: int64Ptr(2), IsLocal: boolPtr(true)},
+		{LineupID: int64(4), TeamID: int64Ptr(1), IsLocal: boolPtr(false)},
+		{LineupID: int64(5), TeamID: int64Ptr(1), IsLocal: boolPtr(false)},
+	} {
+		_, err := s.db.Collection(lineupsTable).Insert(&l)
+		r.Nil(err)
+	}
+
+	for _, lp := range []lineupPlayer{
+		{LineupID: int64(1), PlayerID: int64(1), TeamID: int64Ptr(1), Role: ROLE_STARTER},
+		{LineupID: int64(2), PlayerID: int64(2), TeamID: int64Ptr(2), Role: ROLE_STARTER},
+		{LineupID: int64(5), PlayerID: int64(1), TeamID: int64Ptr(1), Role: ROLE_STARTER},
+	} {
+		_, err := s.db.Collection(lineupPlayersTable).Insert(&lp)
+		r.Nil(err)
+	}
+
+	for _, m := range []match{
+		{MatchID: int64(1), SeasonID: int64Ptr(1), HomeTeamID: int64Ptr(1), AwayTeamID: int64Ptr(2), HomeLineupID: int64Ptr(1), AwayLineupID: int64Ptr(2), Status: MATCH_STATUS_LIVE},
+		{MatchID: int64(2), SeasonID: int64Ptr(1), HomeTeamID: int64Ptr(2), AwayTeamID: int64Ptr(1), HomeLineupID: int64Ptr(3), AwayLineupID: int64Ptr(4), Status: MATCH_STATUS_SCHEDULED},
+	} {
+		_, err := s.db.Collection(matchesTable).Insert(&m)
+		r.Nil(err)
+	}
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "A is sent off",
+			Method: "POST",
+			Target: "/matches/1/actions",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: action{
+				PlayerID: int64(1),
+				Type:     ACTION_CARD_RED,
+				Time:     at(30, 0),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"action_id":1}`,
+		},
+		{
+			Name:               "No suspensions while the match is live",
+			Method:             "GET",
+			Target:             "/teams/1/suspensions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:   "Finish match 1",
+			Method: "PUT",
+			Target: "/matches/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "A is suspended",
+			Method:             "GET",
+			Target:             "/teams/1/suspensions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"team_id":1,"competition_id":1,"reason":"SUSPENSION_REASON_RED_CARD","match_id":1,"matches":1,"remaining":1}]`,
+		},
+		{
+			Name:               "Away team has no suspensions",
+			Method:             "GET",
+			Target:             "/teams/2/suspensions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:               "Unknown team",
+			Method:             "GET",
+			Target:             "/teams/4/suspensions",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"team not found"}`,
+		},
+		{
+			Name:   "A cannot be picked for match 2",
+			Method: "POST",
+			Target: "/lineups/4/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is suspended in the competition for 1 more match(es) (SUSPENSION_REASON_RED_CARD in match 1)"}`,
+		},
+		{
+			Name:   "C can be picked for match 2",
+			Method: "POST",
+			Target: "/lineups/4/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(3),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Roster with A is rejected",
+			Method: "PUT",
+			Target: "/lineups/4/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupRoster{
+				Players: []lineupPlayer{
+					{PlayerID: int64(3)},
+					{PlayerID: int64(1)},
+				},
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"invalid lineup players","errors":[{"index":1,"player_id":1,"message":"player is suspended in the competition for 1 more match(es) (SUSPENSION_REASON_RED_CARD in match 1)"}]}`,
+		},
+		{
+			Name:   "Match with A in a lineup is rejected",
+			Method: "POST",
+			Target: "/matches",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				SeasonID:     int64Ptr(1),
+				HomeTeamID:   int64Ptr(2),
+				AwayTeamID:   int64Ptr(1),
+				AwayLineupID: int64Ptr(5),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is suspended in the competition for 1 more match(es) (SUSPENSION_REASON_RED_CARD in match 1)"}`,
+		},
+		{
+			Name:   "Lineup with A cannot be attached to match 2",
+			Method: "PUT",
+			Target: "/matches/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				AwayLineupID: int64Ptr(5),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is suspended in the competition for 1 more match(es) (SUSPENSION_REASON_RED_CARD in match 1)"}`,
+		},
+		{
+			Name:   "Finish match 2",
+			Method: "PUT",
+			Target: "/matches/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: match{
+				Status: MATCH_STATUS_FINISHED,
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "A has served the suspension",
+			Method:             "GET",
+			Target:             "/teams/1/suspensions",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
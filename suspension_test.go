@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSuspensions(t *testing.T) {
+	fixture := func(matchID, seasonID, home, away int64) match {
+		return match{
+			MatchID:      matchID,
+			SeasonID:     int64Ptr(seasonID),
+			HomeTeamID:   int64Ptr(home),
+			AwayTeamID:   int64Ptr(away),
+			HomeLineupID: int64Ptr(2*matchID - 1),
+			AwayLineupID: int64Ptr(2 * matchID),
+			Status:       MATCH_STATUS_FINISHED,
+		}
+	}
+
+	matches := []match{
+		fixture(1, 1, 10, 20),
+		fixture(2, 1, 20, 30),
+		fixture(3, 1, 10, 30),
+		fixture(4, 2, 20, 10),
+		fixture(5, 2, 30, 20),
+	}
+
+	bookings := []playerMatchStats{
+		{PlayerID: 1, MatchID: 1, LineupID: 1, RedCards: 1},
+		{PlayerID: 2, MatchID: 1, LineupID: 2, YellowCards: 1},
+		// Sent off after a second yellow, only the red card counts.
+		{PlayerID: 3, MatchID: 1, LineupID: 1, YellowCards: 2, RedCards: 1},
+		{PlayerID: 2, MatchID: 2, LineupID: 3, YellowCards: 1},
+		// Yellow cards carry over to the next season of the competition.
+		{PlayerID: 2, MatchID: 4, LineupID: 7, YellowCards: 1},
+		{PlayerID: 2, MatchID: 5, LineupID: 10, YellowCards: 1},
+	}
+
+	policy := suspensionPolicy{redCard: 2, yellowLimit: 2, yellowCards: 1}
+
+	for _, tc := range []struct {
+		Name     string
+		Matches  []match
+		Policy   suspensionPolicy
+		Expected []suspension
+	}{
+		{
+			Name:    "Suspensions pending after the first season",
+			Matches: matches[:3],
+			Policy:  policy,
+			Expected: []suspension{
+				{PlayerID: 1, TeamID: 10, CompetitionID: 1, Reason: SUSPENSION_REASON_RED_CARD, MatchID: 1, Matches: 2, Remaining: 1},
+				{PlayerID: 3, TeamID: 10, CompetitionID: 1, Reason: SUSPENSION_REASON_RED_CARD, MatchID: 1, Matches: 2, Remaining: 1},
+				{PlayerID: 2, TeamID: 20, CompetitionID: 1, Reason: SUSPENSION_REASON_YELLOW_CARDS, MatchID: 2, Matches: 1, Remaining: 1},
+			},
+		},
+		{
+			Name:    "Suspensions served in the next season",
+			Matches: matches,
+			Policy:  policy,
+			Expected: []suspension{
+				{PlayerID: 1, TeamID: 10, CompetitionID: 1, Reason: SUSPENSION_REASON_RED_CARD, MatchID: 1, Matches: 2, Remaining: 0},
+				{PlayerID: 3, TeamID: 10, CompetitionID: 1, Reason: SUSPENSION_REASON_RED_CARD, MatchID: 1, Matches: 2, Remaining: 0},
+				{PlayerID: 2, TeamID: 20, CompetitionID: 1, Reason: SUSPENSION_REASON_YELLOW_CARDS, MatchID: 2, Matches: 1, Remaining: 0},
+				{PlayerID: 2, TeamID: 20, CompetitionID: 1, Reason: SUSPENSION_REASON_YELLOW_CARDS, MatchID: 5, Matches: 1, Remaining: 1},
+			},
+		},
+		{
+			Name:    "Suspensions disabled",
+			Matches: matches,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+			r.Equal(tc.Expected, computeSuspensions(tc.Policy, 1, tc.Matches, bookings))
+		})
+	}
+}
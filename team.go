@@ -0,0 +1,8 @@
+package main
+
+// team is a club. Players are registered to a team and lineups are picked
+// by one.
+type team struct {
+	TeamID int64  `json:"team_id,omitempty" db:"team_id,omitempty"`
+	Name   string `json:"name,omitempty" db:"name,omitempty"`
+}
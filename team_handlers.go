@@ -0,0 +1,140 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+)
+
+func teamID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		str := c.Param("team_id")
+		if str == "" {
+			return next(c)
+		}
+
+		id, err := strconv.ParseInt(str, 10, 64)
+		if err != nil {
+			log.WithField("team_id", str).Debug("Failed to parse `team_id` as int64")
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid `team_id`")
+		}
+
+		c.Set("team_id", id)
+
+		return next(c)
+	}
+}
+
+func getTeamID(c echo.Context) (id int64) {
+	id, _ = c.Get("team_id").(int64)
+	return
+}
+
+const teamsTable = "teams"
+
+var (
+	errTeamNotFound     = errors.New("team not found")
+	errPlayerNotInTeam  = errors.New("player is not registered to the lineup team")
+	errInvalidTeamValue = errors.New("Invalid `team_id` value")
+)
+
+func (s *server) createTeam(c echo.Context) error {
+	req := new(team)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure TeamID is not set.
+	if req.TeamID != 0 {
+		log.WithError(fmt.Errorf("team_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	ret, err := s.db.Collection(teamsTable).Insert(req)
+	if err != nil {
+		log.WithError(err).Error("Failed to insert team in the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	id, err := toInt64(ret)
+	if err != nil {
+		log.WithError(err).Error("Failed to cast autogenerated ID after inserting a team")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &team{
+		TeamID: id,
+	})
+}
+
+func (s *server) getTeam(c echo.Context) error {
+	found := new(team)
+
+	err := s.db.Collection(teamsTable).Find("team_id", getTeamID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("team_id", getTeamID(c)).Debug("team not found")
+		return echo.NewHTTPError(http.StatusNotFound, errTeamNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve team from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, found)
+}
+
+func (s *server) listTeams(c echo.Context) error {
+	limit, page, err := pagination(c)
+	if err != nil {
+		log.WithError(err).Error("Invalid request")
+		return err
+	}
+
+	var teams []team
+
+	err = s.db.Collection(teamsTable).Find().OrderBy("team_id").
+		Paginate(limit).Page(page).All(&teams)
+	if err != nil {
+		log.WithError(err).Error("Failed to list teams from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &teams)
+}
+
+func (s *server) updateTeam(c echo.Context) error {
+	req := new(team)
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+
+	// Ensure TeamID is not set.
+	if req.TeamID != 0 {
+		log.WithError(fmt.Errorf("team_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	err := s.db.Collection(teamsTable).Find("team_id", getTeamID(c)).Update(req)
+	if err != nil {
+		log.WithError(err).Error("Failed to update team from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *server) deleteTeam(c echo.Context) error {
+	err := s.db.Collection(teamsTable).Find("team_id", getTeamID(c)).Delete()
+	if err != nil {
+		log.WithError(err).Error("Failed to delete team from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeamCRUD(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "`team_id` explictly set on create",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				TeamID: int64(1),
+				Name:   "Foo FC",
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Create first team",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Foo FC",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1}`,
+		},
+		{
+			Name:   "Create second team",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Bar United",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":2}`,
+		},
+		{
+			Name:               "List both teams",
+			Method:             "GET",
+			Target:             "/teams",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"team_id":1,"name":"Foo FC"},{"team_id":2,"name":"Bar United"}]`,
+		},
+		{
+			Name:   "Rename second team",
+			Method: "PUT",
+			Target: "/teams/2",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Bar City",
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Get second team",
+			Method:             "GET",
+			Target:             "/teams/2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":2,"name":"Bar City"}`,
+		},
+		{
+			Name:   "Register a player to an unknown team",
+			Method: "POST",
+			Target: "/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				TeamID:      int64Ptr(3),
+				DisplayName: "Foo",
+				Number:      9,
+				Position:    POSITION_STRIKER,
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"team not found"}`,
+		},
+		{
+			Name:   "Register a player to the first team",
+			Method: "POST",
+			Target: "/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				TeamID:      int64Ptr(1),
+				DisplayName: "Foo",
+				Number:      9,
+				Position:    POSITION_STRIKER,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1}`,
+		},
+		{
+			Name:   "Register a player to the second team",
+			Method: "POST",
+			Target: "/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				TeamID:      int64Ptr(2),
+				DisplayName: "Bar",
+				Number:      1,
+				Position:    POSITION_GOALKEEPER,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":2}`,
+		},
+		{
+			Name:               "List players of the first team",
+			Method:             "GET",
+			Target:             "/players?team_id=1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":1,"team_id":1,"display_name":"Foo","number":9,"position":"POSITION_STRIKER"}]`,
+		},
+		{
+			Name:               "List goalkeepers of the second team",
+			Method:             "GET",
+			Target:             "/players?position=POSITION_GOALKEEPER&team_id=2",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"player_id":2,"team_id":2,"display_name":"Bar","number":1,"position":"POSITION_GOALKEEPER"}]`,
+		},
+		{
+			Name:               "Invalid `team_id` filter",
+			Method:             "GET",
+			Target:             "/players?team_id=foo",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`team_id`" + ` value"}`,
+		},
+		{
+			Name:   "Create a lineup of the first team",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				TeamID:        int64Ptr(1),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1}`,
+		},
+		{
+			Name:   "Add a player of another team to the lineup",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(2),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is not registered to the lineup team"}`,
+		},
+		{
+			Name:   "Add a player of the team to the lineup",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:   "Replace the roster with a player of another team",
+			Method: "PUT",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupRoster{
+				Players: []lineupPlayer{
+					{PlayerID: int64(1)},
+					{PlayerID: int64(2)},
+				},
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"invalid lineup players","errors":[{"index":1,"player_id":2,"message":"player is not registered to the lineup team"}]}`,
+		},
+		{
+			Name:   "Hand the lineup over to a team its players do not belong to",
+			Method: "PUT",
+			Target: "/lineups/1",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				TeamID: int64Ptr(2),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is not registered to the lineup team"}`,
+		},
+		{
+			Name:               "Delete first team",
+			Method:             "DELETE",
+			Target:             "/teams/1",
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Attempt to get deleted team",
+			Method:             "GET",
+			Target:             "/teams/1",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"team not found"}`,
+		},
+		{
+			Name:               "Players of the deleted team are kept without a team",
+			Method:             "GET",
+			Target:             "/players/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"display_name":"Foo","number":9,"position":"POSITION_STRIKER"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+const (
+	sideHome = "home"
+	sideAway = "away"
+)
+
+type timelinePlayer struct {
+	PlayerID    int64  `json:"player_id"`
+	DisplayName string `json:"display_name"`
+}
+
+// timelineEntry is an event of the match timeline. Assists are not listed on
+// their own but attached to the goal they belong to. Events derived by the
+// rules of the game have no ID but the one of the action they follow from.
+type timelineEntry struct {
+	ActionID    int64           `json:"action_id,omitempty"`
+	Time        matchTime       `json:"time"`
+	Type        actionType      `json:"action"`
+	Side        string          `json:"side"`
+	PlayerID    int64           `json:"player_id"`
+	DisplayName string          `json:"display_name"`
+	Assist      *timelinePlayer `json:"assist,omitempty"`
+	Substitute  *timelinePlayer `json:"substitute,omitempty"`
+	Score       score           `json:"score"`
+	Annulled    bool            `json:"annulled,omitempty"`
+	DerivedFrom int64           `json:"derived_from,omitempty"`
+}
+
+func (st *matchState) timelinePlayer(playerID int64) *timelinePlayer {
+	tp := &timelinePlayer{PlayerID: playerID}
+	if p, ok := st.players[playerID]; ok {
+		tp.DisplayName = p.DisplayName
+	}
+	return tp
+}
+
+// side returns whether the lineup plays as the home or the away side.
+func (st *matchState) side(lineupID int64) string {
+	if st.match.isHome(lineupID) {
+		return sideHome
+	}
+	return sideAway
+}
+
+// timeline replays the actions returning the events of the match in order,
+// each with the score right after it. Annulled actions are listed marked as
+// such without counting for the score, and annulled assists are listed on
+// their own.
+func (st *matchState) timeline(actions []action) []timelineEntry {
+	entries := []timelineEntry{}
+	goals := map[int64]int{}
+
+	for i := range actions {
+		a := &actions[i]
+
+		var derived []action
+		if !a.Annulled {
+			derived = st.apply(a)
+		}
+
+		if a.Type == ACTION_ASSIST && !a.Annulled {
+			if a.GoalID == nil {
+				continue
+			}
+			if j, ok := goals[*a.GoalID]; ok {
+				entries[j].Assist = st.timelinePlayer(a.PlayerID)
+			}
+			continue
+		}
+
+		p := st.timelinePlayer(a.PlayerID)
+		entry := timelineEntry{
+			ActionID:    a.ActionID,
+			Time:        a.Time,
+			Type:        a.Type,
+			Side:        st.side(a.LineupID),
+			PlayerID:    p.PlayerID,
+			DisplayName: p.DisplayName,
+			Score:       st.score,
+			Annulled:    a.Annulled,
+		}
+
+		if a.SubstituteID != nil {
+			entry.Substitute = st.timelinePlayer(*a.SubstituteID)
+		}
+
+		if a.Type == ACTION_GOAL && !a.Annulled {
+			goals[a.ActionID] = len(entries)
+		}
+
+		entries = append(entries, entry)
+
+		for _, d := range derived {
+			p := st.timelinePlayer(d.PlayerID)
+			entries = append(entries, timelineEntry{
+				Time:        d.Time,
+				Type:        d.Type,
+				Side:        st.side(d.LineupID),
+				PlayerID:    p.PlayerID,
+				DisplayName: p.DisplayName,
+				Score:       st.score,
+				DerivedFrom: d.DerivedFrom,
+			})
+		}
+	}
+
+	return entries
+}
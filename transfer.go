@@ -0,0 +1,80 @@
+package main
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// date is a calendar day, written as 2006-01-02.
+type date time.Time
+
+func parseDate(s string) (date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return date{}, fmt.Errorf("Could not parse %s", s)
+	}
+	return date(t), nil
+}
+
+// dateOf returns the day of the time in UTC.
+func dateOf(t time.Time) date {
+	t = t.UTC()
+	return date(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+func today() date {
+	return dateOf(time.Now())
+}
+
+func (d date) IsZero() bool {
+	return time.Time(d).IsZero()
+}
+
+// before reports whether d is an earlier day than o.
+func (d date) before(o date) bool {
+	return time.Time(d).Before(time.Time(o))
+}
+
+func (d date) String() string {
+	return time.Time(d).Format(dateLayout)
+}
+
+func (d date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *date) UnmarshalText(b []byte) error {
+	parsed, err := parseDate(string(b))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d date) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+func (d *date) Scan(src interface{}) error {
+	t, ok := src.(time.Time)
+	if !ok {
+		return fmt.Errorf("Failed to scan %v (%T) as a date", src, src)
+	}
+	*d = date(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+	return nil
+}
+
+// transfer is a move of a player from one team to another, effective from
+// Date on. A missing FromTeamID means the player was a free agent and a
+// missing ToTeamID that they were released.
+type transfer struct {
+	TransferID int64  `json:"transfer_id,omitempty" db:"transfer_id,omitempty"`
+	PlayerID   int64  `json:"player_id,omitempty" db:"player_id,omitempty"`
+	FromTeamID *int64 `json:"from_team_id,omitempty" db:"from_team_id,omitempty"`
+	ToTeamID   *int64 `json:"to_team_id,omitempty" db:"to_team_id,omitempty"`
+	Date       date   `json:"date" db:"date"`
+}
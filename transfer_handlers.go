@@ -0,0 +1,300 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/labstack/echo/v4"
+	"upper.io/db.v3"
+	"upper.io/db.v3/lib/sqlbuilder"
+)
+
+const transfersTable = "transfers"
+
+var (
+	errTransferOutOfOrder = errors.New("transfer is dated before the last transfer of the player")
+	errAlreadyInTeam      = errors.New("player is already registered to the team")
+	errNoTeam             = errors.New("player is not registered to any team")
+)
+
+// lockPlayer retrieves the player locking its row until the transaction
+// ends, so concurrent transfers of the same player are serialized.
+func lockPlayer(tx sqlbuilder.Tx, playerID int64) (*player, error) {
+	found := new(player)
+
+	err := tx.SelectFrom(playersTable).Where("player_id", playerID).
+		Amend(func(query string) string {
+			return query + " FOR UPDATE"
+		}).One(found)
+	if err == db.ErrNoMoreRows {
+		return nil, errPlayerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return found, nil
+}
+
+func sameTeam(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// createTransfer records a transfer of the player away from the team their
+// last transfer takes them to. Transfers are recorded in the order they
+// happen and the player is registered to the new team once its date comes,
+// right away unless it is dated in the future.
+func (s *server) createTransfer(c echo.Context) error {
+	req := new(transfer)
+	if err := c.Bind(req); err != nil {
+		log.WithError(err).Error("Invalid request")
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	// Ensure TransferID, PlayerID and FromTeamID are not set.
+	if req.TransferID != 0 || req.PlayerID != 0 || req.FromTeamID != nil {
+		log.WithError(fmt.Errorf("transfer_id, player_id or from_team_id was set")).Error("Invalid request")
+		return c.NoContent(http.StatusUnprocessableEntity)
+	}
+
+	if req.Date.IsZero() {
+		log.WithError(fmt.Errorf("`date` was not set")).Error("Invalid request")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `date` value")
+	}
+
+	err := s.tx(func(tx sqlbuilder.Tx) error {
+		p, err := lockPlayer(tx, getPlayerID(c))
+		if err != nil {
+			return err
+		}
+
+		last := new(transfer)
+		err = tx.SelectFrom(transfersTable).Where("player_id", p.PlayerID).
+			OrderBy("-date", "-transfer_id").Limit(1).One(last)
+		if err != nil && err != db.ErrNoMoreRows {
+			return err
+		}
+		if err == nil && req.Date.before(last.Date) {
+			return errTransferOutOfOrder
+		}
+
+		// A transfer still to come has already been agreed, so it is the team
+		// the player leaves.
+		from := p.TeamID
+		if err == nil {
+			from = last.ToTeamID
+		}
+
+		if sameTeam(from, req.ToTeamID) {
+			return errAlreadyInTeam
+		}
+
+		req.PlayerID = p.PlayerID
+		req.FromTeamID = from
+
+		ret, err := tx.Collection(transfersTable).Insert(req)
+		if isForeignKeyViolation(err) {
+			return errTeamNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		req.TransferID, err = toInt64(ret)
+		if err != nil {
+			return err
+		}
+
+		if today().before(req.Date) {
+			return nil
+		}
+
+		return tx.Collection(playersTable).Find("player_id", p.PlayerID).Update(map[string]interface{}{
+			"team_id": req.ToTeamID,
+		})
+	})
+	switch err {
+	case nil:
+	case errPlayerNotFound:
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errTeamNotFound, errTransferOutOfOrder, errAlreadyInTeam:
+		log.WithError(err).Debug("Invalid transfer")
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	case errTxConflict:
+		log.WithError(err).Debug("Conflicting player update")
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		log.WithError(err).Error("Failed to insert transfer in the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	s.purgePlayer(req.PlayerID)
+
+	return c.JSON(http.StatusOK, &transfer{
+		TransferID: req.TransferID,
+	})
+}
+
+// purgePlayer drops the cached player after they are registered to another
+// team.
+func (s *server) purgePlayer(playerID int64) {
+	if s.config.disableCache {
+		return
+	}
+
+	err := s.redis.Del(fmt.Sprintf("/players/%d", playerID)).Err()
+	if err != nil {
+		log.WithError(err).WithField("player_id", playerID).Error("Failed to purge player")
+	}
+}
+
+// applyTransfers registers the players to the team of their last transfer
+// that has already taken effect, for the transfers that were recorded ahead
+// of their date.
+func (s *server) applyTransfers() error {
+	rows, err := s.db.Query(`UPDATE players AS p SET team_id = t.to_team_id
+		FROM (
+			SELECT DISTINCT ON (player_id) player_id, to_team_id FROM transfers
+			WHERE date <= ? ORDER BY player_id, date DESC, transfer_id DESC
+		) AS t
+		WHERE p.player_id = t.player_id AND p.team_id IS DISTINCT FROM t.to_team_id
+		RETURNING p.player_id`, today())
+	if err != nil {
+		return err
+	}
+
+	var moved []player
+	if err := sqlbuilder.NewIterator(rows).All(&moved); err != nil {
+		return err
+	}
+
+	for _, p := range moved {
+		s.purgePlayer(p.PlayerID)
+	}
+
+	return nil
+}
+
+// applyTransfersEvery applies the transfers that take effect as days go by.
+func (s *server) applyTransfersEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.applyTransfers(); err != nil {
+			log.WithError(err).Error("Failed to apply transfers")
+		}
+	}
+}
+
+// playerTransfers returns the transfers of the player in the order they
+// happened.
+func playerTransfers(sess sqlbuilder.SQLBuilder, playerID int64) ([]transfer, error) {
+	transfers := []transfer{}
+
+	err := sess.SelectFrom(transfersTable).Where("player_id", playerID).
+		OrderBy("date", "transfer_id").All(&transfers)
+	if err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+func (s *server) listPlayerTransfers(c echo.Context) error {
+	found := new(player)
+
+	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	transfers, err := playerTransfers(s.db, found.PlayerID)
+	if err != nil {
+		log.WithError(err).Error("Failed to list transfers from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, &transfers)
+}
+
+// teamOnDate returns the team the player was registered to on the given day
+// according to their transfers, nil if they had none.
+func teamOnDate(p *player, transfers []transfer, on date) *int64 {
+	if len(transfers) == 0 {
+		return p.TeamID
+	}
+
+	// Before their first transfer players belonged to the team they left.
+	teamID := transfers[0].FromTeamID
+	for _, t := range transfers {
+		if on.before(t.Date) {
+			break
+		}
+		teamID = t.ToTeamID
+	}
+
+	return teamID
+}
+
+// getPlayerTeam returns the team the player is registered to, or the one
+// they were registered to on the day given by the `date` param.
+func (s *server) getPlayerTeam(c echo.Context) error {
+	found := new(player)
+
+	err := s.db.Collection(playersTable).Find("player_id", getPlayerID(c)).One(found)
+	if err == db.ErrNoMoreRows {
+		log.WithField("player_id", getPlayerID(c)).Debug("player not found")
+		return echo.NewHTTPError(http.StatusNotFound, errPlayerNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve player from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	teamID := found.TeamID
+
+	if str := c.QueryParam("date"); str != "" {
+		on, err := parseDate(str)
+		if err != nil {
+			log.WithError(err).Error("Invalid request")
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Invalid `date` value")
+		}
+
+		transfers, err := playerTransfers(s.db, found.PlayerID)
+		if err != nil {
+			log.WithError(err).Error("Failed to list transfers from the store")
+			return c.NoContent(http.StatusInternalServerError)
+		}
+
+		teamID = teamOnDate(found, transfers, on)
+	}
+
+	if teamID == nil {
+		log.WithField("player_id", found.PlayerID).Debug("player has no team")
+		return echo.NewHTTPError(http.StatusNotFound, errNoTeam.Error())
+	}
+
+	t := new(team)
+
+	err = s.db.Collection(teamsTable).Find("team_id", *teamID).One(t)
+	if err == db.ErrNoMoreRows {
+		log.WithField("team_id", *teamID).Debug("team not found")
+		return echo.NewHTTPError(http.StatusNotFound, errTeamNotFound.Error())
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to retrieve team from the store")
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
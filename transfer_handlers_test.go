@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func mustDate(s string) date {
+	d, err := parseDate(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestPlayerTransfers(t *testing.T) {
+	s := testServer()
+	defer s.db.Close()
+
+	for _, tc := range []struct {
+		Name               string
+		Method             string
+		Target             string
+		RequestSetup       func(*http.Request)
+		Body               interface{}
+		ExpectedStatusCode int
+		ExpectedBody       string
+	}{
+		{
+			Name:   "Create first team",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Foo FC",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1}`,
+		},
+		{
+			Name:   "Create second team",
+			Method: "POST",
+			Target: "/teams",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: team{
+				Name: "Bar United",
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":2}`,
+		},
+		{
+			Name:   "Register a player to the first team",
+			Method: "POST",
+			Target: "/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: player{
+				TeamID:      int64Ptr(1),
+				DisplayName: "Foo",
+				Number:      9,
+				Position:    POSITION_STRIKER,
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1}`,
+		},
+		{
+			Name:   "Create a lineup of the first team",
+			Method: "POST",
+			Target: "/lineups",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineup{
+				TeamID:        int64Ptr(1),
+				FormationName: "FORMATION_FOUR_FOUR_TWO",
+				IsLocal:       boolPtr(true),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1}`,
+		},
+		{
+			Name:   "Add the player to the lineup",
+			Method: "POST",
+			Target: "/lineups/1/players",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: lineupPlayer{
+				PlayerID: int64(1),
+			},
+			ExpectedStatusCode: http.StatusOK,
+		},
+		{
+			Name:               "Player has no transfers",
+			Method:             "GET",
+			Target:             "/players/1/transfers",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[]`,
+		},
+		{
+			Name:               "Team of a player without transfers",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2019-01-01",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1,"name":"Foo FC"}`,
+		},
+		{
+			Name:   "`from_team_id` explicitly set",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				FromTeamID: int64Ptr(2),
+				ToTeamID:   int64Ptr(2),
+				Date:       mustDate("2020-07-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		{
+			Name:   "Transfer without a date",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(2),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`date`" + ` value"}`,
+		},
+		{
+			Name:   "Transfer of an unknown player",
+			Method: "POST",
+			Target: "/players/2/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(2),
+				Date:     mustDate("2020-07-01"),
+			},
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player not found"}`,
+		},
+		{
+			Name:   "Transfer to an unknown team",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(3),
+				Date:     mustDate("2020-07-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"team not found"}`,
+		},
+		{
+			Name:   "Transfer to the team of the player",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(1),
+				Date:     mustDate("2020-07-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is already registered to the team"}`,
+		},
+		{
+			Name:   "Transfer to the second team",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(2),
+				Date:     mustDate("2020-07-01"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"transfer_id":2}`,
+		},
+		{
+			Name:   "Transfer dated before the last one",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(1),
+				Date:     mustDate("2020-06-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"transfer is dated before the last transfer of the player"}`,
+		},
+		{
+			Name:               "Player is registered to the second team",
+			Method:             "GET",
+			Target:             "/players/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"team_id":2,"display_name":"Foo","number":9,"position":"POSITION_STRIKER"}`,
+		},
+		{
+			Name:               "Past lineups keep the team of the player",
+			Method:             "GET",
+			Target:             "/lineups/1?with-players=true",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"lineup_id":1,"team_id":1,"formation":"FORMATION_FOUR_FOUR_TWO","is_local":true,"players":[{"player_id":1,"team_id":1,"display_name":"Foo","number":9,"position":"POSITION_STRIKER"}]}`,
+		},
+		{
+			Name:   "Release the player",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				Date: mustDate("2021-01-01"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"transfer_id":3}`,
+		},
+		{
+			Name:               "List transfers",
+			Method:             "GET",
+			Target:             "/players/1/transfers",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `[{"transfer_id":2,"player_id":1,"from_team_id":1,"to_team_id":2,"date":"2020-07-01"},{"transfer_id":3,"player_id":1,"from_team_id":2,"date":"2021-01-01"}]`,
+		},
+		{
+			Name:               "Team before the first transfer",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2020-06-30",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1,"name":"Foo FC"}`,
+		},
+		{
+			Name:               "Team on the day of a transfer",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2020-07-01",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":2,"name":"Bar United"}`,
+		},
+		{
+			Name:               "Team after being released",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2021-02-01",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player is not registered to any team"}`,
+		},
+		{
+			Name:               "Current team of a released player",
+			Method:             "GET",
+			Target:             "/players/1/team",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBody:       `{"message":"player is not registered to any team"}`,
+		},
+		{
+			Name:   "Sign the player for a future season",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(1),
+				Date:     mustDate("2999-07-01"),
+			},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"transfer_id":4}`,
+		},
+		{
+			Name:               "Player is not registered until the transfer takes effect",
+			Method:             "GET",
+			Target:             "/players/1",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"player_id":1,"display_name":"Foo","number":9,"position":"POSITION_STRIKER"}`,
+		},
+		{
+			Name:   "Transfer to the team the player is signed for",
+			Method: "POST",
+			Target: "/players/1/transfers",
+			RequestSetup: func(req *http.Request) {
+				req.Header.Set("Content-Type", "application/json")
+			},
+			Body: transfer{
+				ToTeamID: int64Ptr(1),
+				Date:     mustDate("3000-01-01"),
+			},
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"player is already registered to the team"}`,
+		},
+		{
+			Name:               "Team once the transfer takes effect",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2999-07-01",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBody:       `{"team_id":1,"name":"Foo FC"}`,
+		},
+		{
+			Name:               "Invalid `date` param",
+			Method:             "GET",
+			Target:             "/players/1/team?date=2020-13-01",
+			ExpectedStatusCode: http.StatusUnprocessableEntity,
+			ExpectedBody:       `{"message":"Invalid ` + "`date`" + ` value"}`,
+		},
+	} {
+		t.Run(tc.Name, func(t *testing.T) {
+			r := require.New(t)
+
+			var req *http.Request
+
+			if tc.Body == nil {
+				req = httptest.NewRequest(tc.Method, tc.Target, nil)
+			} else {
+				body, err := json.Marshal(tc.Body)
+				r.Nil(err)
+				req = httptest.NewRequest(tc.Method, tc.Target, bytes.NewBuffer(body))
+			}
+
+			if tc.RequestSetup != nil {
+				tc.RequestSetup(req)
+			}
+
+			rec := httptest.NewRecorder()
+
+			s.web.ServeHTTP(rec, req)
+			resp := rec.Result()
+			r.Equal(tc.ExpectedStatusCode, resp.StatusCode)
+
+			data, err := ioutil.ReadAll(resp.Body)
+			r.Nil(err)
+			r.Equal(tc.ExpectedBody, strings.TrimRight(string(data), "\n"))
+		})
+	}
+}
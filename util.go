@@ -1,6 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
 
 func toInt64(val interface{}) (int64, error) {
 	n, ok := val.(int64)
@@ -11,6 +18,67 @@ func toInt64(val interface{}) (int64, error) {
 	return n, nil
 }
 
+func toUint(str string) (uint, error) {
+	val, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(val), nil
+}
+
 func boolPtr(val bool) *bool {
 	return &val
 }
+
+func int64Ptr(val int64) *int64 {
+	return &val
+}
+
+func intPtr(val int) *int {
+	return &val
+}
+
+// pagination parses the `limit` and `page` query params shared by the list
+// endpoints.
+func pagination(c echo.Context) (limit uint, page uint, err error) {
+	limit = uint(10)
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		limit, err = toUint(limitStr)
+		if err != nil {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid `limit`")
+		}
+	}
+
+	if limit > 100 {
+		return 0, 0, echo.NewHTTPError(http.StatusUnprocessableEntity, "`limit` cannot be greater than 100")
+	}
+
+	page = uint(1)
+	if pageStr := c.QueryParam("page"); pageStr != "" {
+		page, err = toUint(pageStr)
+		if err != nil || page < 1 {
+			return 0, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid `page`")
+		}
+	}
+
+	return limit, page, nil
+}
+
+func isUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether the statement referenced a row that
+// does not exist.
+func isForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
+
+// isTxConflict reports whether postgres aborted a transaction because of a
+// serialization failure or a deadlock, in which case it is safe to retry it.
+func isTxConflict(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+}